@@ -0,0 +1,441 @@
+package AMF
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"github.com/hydra13142/encoding"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type loop struct {
+	Name string `amf:"name"`
+	Next *loop  `amf:"next"`
+}
+
+func TestEncodeCycle(t *testing.T) {
+	x := &loop{Name: "a"}
+	x.Next = x
+	for _, c := range []struct {
+		codec string
+		want  string
+	}{
+		{"amf0", "03" + "00046e616d65" + "02000161" + "00046e657874" + "070000" + "000009"},
+		{"amf3", "11" + "0a23" + "01" + "096e616d65" + "096e657874" + "060361" + "0a00"},
+	} {
+		w := bytes.NewBuffer(nil)
+		e := NewEncoder(w)
+		e.Refer = true
+		if err := e.Encode(x, c.codec); err != nil {
+			t.Fatalf("%s: %v", c.codec, err)
+		}
+		if got := hex.EncodeToString(w.Bytes()); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.codec, got, c.want)
+		}
+	}
+	if _, err := Marshal(x, "amf0"); err == nil {
+		t.Error("cycle without Refer: expected error")
+	}
+}
+
+type named struct {
+	Class string  `amf:"$"`
+	X     float64 `amf:"x"`
+}
+
+type link struct {
+	Class string `amf:"$"`
+	Next  *link  `amf:"next"`
+}
+
+type blob struct {
+	Class string `amf:"$"`
+	B     []byte `amf:"b"`
+	Child *link  `amf:"child"`
+}
+
+func TestTypedObject(t *testing.T) {
+	b, err := Marshal(named{"Test", 1}, "amf0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "10" + "000454657374" + "000178" + "003ff0000000000000" + "000009"
+	if got := hex.EncodeToString(b); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	var y named
+	if err = Unmarshal(b, &y); err != nil || y != (named{"Test", 1}) {
+		t.Fatalf("round trip: %+v, %v", y, err)
+	}
+}
+
+func TestReferenceTable(t *testing.T) {
+	c := &link{Class: "L"}
+	c.Next = c
+	w := bytes.NewBuffer(nil)
+	e := NewEncoder(w)
+	e.Refer = true
+	if err := e.Encode(&blob{"B", []byte("xy"), c}, "amf3"); err != nil {
+		t.Fatal(err)
+	}
+	// 对象表：0为blob，1为字节数组，2为child
+	want := "11" + "0a23" + "0342" + "0362" + "0b6368696c64" + "0c057879" +
+		"0a13" + "034c" + "096e657874" + "0a04"
+	if got := hex.EncodeToString(w.Bytes()); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	var v map[string]interface{}
+	if err := Unmarshal(w.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if string(v["b"].([]byte)) != "xy" || v["child"].(map[string]interface{})["$"] != "L" {
+		t.Fatalf("round trip: %v", v)
+	}
+}
+
+func TestReferencePerValue(t *testing.T) {
+	c := &link{Class: "L"}
+	c.Next = c
+	for _, v := range []string{"amf0", "amf3"} {
+		w := bytes.NewBuffer(nil)
+		e := NewEncoder(w)
+		e.Refer = true
+		if err := e.Encode(c, v); err != nil {
+			t.Fatal(err)
+		}
+		n := w.Len()
+		if err := e.Encode(c, v); err != nil {
+			t.Fatal(err)
+		}
+		// 第二个值的引用和字符串不指向第一个值
+		b := w.Bytes()
+		if !bytes.Equal(b[:n], b[n:]) {
+			t.Fatalf("%s: got %x, then %x", v, b[:n], b[n:])
+		}
+		d := NewDecoder(bytes.NewReader(b))
+		d.AMF3 = v == "amf3"
+		for i := 0; i < 2; i++ {
+			x, err := d.DecodeNode()
+			if err != nil || x.Str != "L" || x.Len() != 1 {
+				t.Fatalf("%s: value %d: %v", v, i, err)
+			}
+		}
+		var y interface{}
+		if err := Unmarshal(b[n:], &y); err != nil {
+			t.Fatalf("%s: second value alone: %v", v, err)
+		}
+	}
+}
+
+func TestEmptyString(t *testing.T) {
+	x := []string{"", "a", "", "a"}
+	b, err := Marshal(x, "amf3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 空字符串不进入字符串引用表
+	want := "11" + "0909" + "01" + "0601" + "060361" + "0601" + "0600"
+	if got := hex.EncodeToString(b); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	var y []string
+	if err = Unmarshal(b, &y); err != nil || len(y) != 4 || y[1] != "a" || y[3] != "a" || y[2] != "" {
+		t.Fatalf("round trip: %q, %v", y, err)
+	}
+}
+
+type dated struct {
+	T time.Time `amf:"t"`
+	N float64   `amf:"n"`
+}
+
+func TestDateAMF3(t *testing.T) {
+	x := dated{time.Unix(1, 5e8), 2}
+	b, err := Marshal(x, "amf3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 日期之后没有时区字段
+	want := "11" + "0a23" + "01" + "0374" + "036e" + "0801" + "4097700000000000" + "054000000000000000"
+	if got := hex.EncodeToString(b); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	var y dated
+	if err = Unmarshal(b, &y); err != nil || !y.T.Equal(x.T) || y.N != 2 {
+		t.Fatalf("round trip: %v, %v", y, err)
+	}
+	// 旧版本编码器在日期之后多写了两个字节
+	old, _ := hex.DecodeString("11" + "0a23" + "01" + "0374" + "036e" + "0801" + "4097700000000000" + "0000" + "054000000000000000")
+	d := NewDecoder(bytes.NewReader(old))
+	d.LegacyDates()
+	y = dated{}
+	if err = d.Decode(&y); err != nil || !y.T.Equal(x.T) || y.N != 2 {
+		t.Fatalf("legacy: %v, %v", y, err)
+	}
+}
+
+func TestDecodeAMF3(t *testing.T) {
+	// 不带0x11前缀的AMF3值，以及Marshal按AMF3输出的带前缀的值
+	for _, s := range []string{
+		"0a23" + "01" + "0374" + "036e" + "0801" + "4097700000000000" + "054000000000000000",
+		"11" + "0a23" + "01" + "0374" + "036e" + "0801" + "4097700000000000" + "054000000000000000",
+	} {
+		b, _ := hex.DecodeString(s)
+		d := NewDecoder(bytes.NewReader(b))
+		d.AMF3 = true
+		var y dated
+		if err := d.Decode(&y); err != nil || !y.T.Equal(time.Unix(1, 5e8)) || y.N != 2 {
+			t.Fatalf("%s: got %v, %v", s, y, err)
+		}
+	}
+}
+
+func TestDateAMF0(t *testing.T) {
+	// 时区字段为480（+8:00）的日期，解码结果不受其影响
+	b, _ := hex.DecodeString("0b" + "4097700000000000" + "01e0")
+	var y time.Time
+	if err := Unmarshal(b, &y); err != nil || !y.Equal(time.Unix(1, 5e8)) {
+		t.Fatalf("got %v, %v", y, err)
+	}
+	x, err := Marshal(time.Unix(1, 5e8), "amf0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(x), "0b"+"4097700000000000"+"0000"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestDynamicTraits(t *testing.T) {
+	// 两个动态对象，第二个使用第一个的特征引用
+	b, _ := hex.DecodeString("11" + "090501" +
+		"0a1b" + "01" + "0378" + "0402" + "0379" + "0403" + "01" +
+		"0a01" + "0404" + "037a" + "0405" + "01")
+	var y []map[string]interface{}
+	if err := Unmarshal(b, &y); err != nil {
+		t.Fatal(err)
+	}
+	if len(y) != 2 || y[0]["x"] != int64(2) || y[0]["@y"] != int64(3) || y[1]["x"] != int64(4) || y[1]["@z"] != int64(5) {
+		t.Fatalf("got %v", y)
+	}
+}
+
+type sparse struct {
+	L []int `amf:"l,omitempty"`
+}
+
+func TestKeepEmpty(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		var w bytes.Buffer
+		e := NewEncoder(&w)
+		if keep {
+			e.KeepEmpty()
+		}
+		if err := e.Encode(sparse{[]int{}}, "amf0"); err != nil {
+			t.Fatal(err)
+		}
+		want := "03" + "000009"
+		if keep {
+			want = "03" + "00016c" + "0a00000000" + "000009"
+		}
+		if got := hex.EncodeToString(w.Bytes()); got != want {
+			t.Errorf("keep %v: got %s, want %s", keep, got, want)
+		}
+	}
+}
+
+func TestDecodeNode(t *testing.T) {
+	// 列表的第二个成员引用第一个对象
+	b, _ := hex.DecodeString("11" + "090501" + "0a0b" + "01" + "0378" + "0401" + "01" + "0a02")
+	n, err := NewDecoder(bytes.NewReader(b)).DecodeNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Kind != encoding.ListNode || n.Len() != 2 || n.Index(0) != n.Index(1) {
+		t.Fatalf("got %+v", n)
+	}
+	if x := n.Get("[0]"); x.Kind != encoding.ObjectNode || x.Key("@x").Int != 1 {
+		t.Fatalf("got %+v", x)
+	}
+}
+
+func TestEncodeNode(t *testing.T) {
+	// 关联数组、匿名对象与具名对象经过节点后保持原样
+	for _, s := range []string{
+		"11" + "0901" + "0361" + "0402" + "01",
+		"03" + "000162" + "0101" + "000009",
+		"10" + "000154" + "000162" + "0100" + "000009",
+	} {
+		b, _ := hex.DecodeString(s)
+		n, err := NewDecoder(bytes.NewReader(b)).DecodeNode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var w bytes.Buffer
+		e := NewEncoder(&w)
+		e.AMF3 = s[:2] == "11"
+		if err = e.EncodeNode(n); err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(w.Bytes()); got != s {
+			t.Errorf("got %s, want %s", got, s)
+		}
+	}
+}
+
+func TestUnmatchedOffset(t *testing.T) {
+	// 第二个对象的n字段为字符串，其偏移为该字符串的类型标记
+	b, _ := hex.DecodeString("0a00000002" +
+		"03" + "00016e" + "003ff0000000000000" + "000009" +
+		"03" + "00016e" + "02000178" + "000009")
+	var y []dated
+	err := Unmarshal(b, &y)
+	var u *encoding.UnmatchedError
+	if !errors.As(err, &u) || u.Offset != 25 || u.Path != "[1].N" {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	nested := []interface{}{[]interface{}{[]interface{}{"x"}}}
+	a0, _ := Marshal(nested, "amf0")
+	a3, _ := Marshal(nested, "amf3")
+	for _, c := range []struct {
+		in   []byte
+		lim  encoding.Limits
+		want error
+	}{
+		{a0, encoding.Limits{MaxDepth: 3}, nil},
+		{a0, encoding.Limits{MaxDepth: 2}, encoding.NestingTooDeep},
+		{a3, encoding.Limits{MaxDepth: 2}, encoding.NestingTooDeep},
+		// 长度很大的long string和AMF3数组在分配之前即报错
+		{[]byte{0x0c, 0xff, 0xff, 0xff, 0xff, 'a'}, encoding.Limits{MaxString: 1 << 20}, encoding.StringTooLong},
+		{[]byte{0x0a, 0xff, 0xff, 0xff, 0xff}, encoding.Limits{MaxLength: 1000}, encoding.TooManyItems},
+		{a3, encoding.Limits{MaxBytes: 4}, encoding.ValueTooLarge},
+	} {
+		var v interface{}
+		d := NewDecoder(bytes.NewReader(c.in))
+		d.SetLimits(c.lim)
+		err := d.Decode(&v)
+		if c.want == nil && err != nil || c.want != nil && !errors.Is(err, c.want) {
+			t.Errorf("%x: got %v, want %v", c.in, err, c.want)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 2}
+	for _, v := range []string{"amf0", "amf3"} {
+		b, err := Marshal(m, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, err := UnmarshalAs[map[string]int](b)
+		if err != nil || !reflect.DeepEqual(y, m) {
+			t.Fatalf("%s: got %v, %v", v, y, err)
+		}
+		var f *encoding.FormatError
+		if err = Unmarshal(append(b, 0x05), &y); !errors.As(err, &f) || f.Offset != int64(len(b)) {
+			t.Fatalf("%s: got %v, want trailing data at %d", v, err, len(b))
+		}
+	}
+	if _, err := Marshal(1, "amf4"); err == nil {
+		t.Error("unknown version accepted")
+	}
+}
+
+// 每层是两次引用上一层的数组，完全展开后有2^n个叶子
+type tree []tree
+
+func TestSharedReferences(t *testing.T) {
+	const n = 40
+	b := []byte{0x0a, 0, 0, 0, n + 1, 0x0a, 0, 0, 0, 0}
+	for i := 1; i <= n; i++ {
+		b = append(b, 0x0a, 0, 0, 0, 2, 0x07, 0, byte(i), 0x07, 0, byte(i))
+	}
+	var x interface{}
+	if err := Unmarshal(b, &x); err != nil {
+		t.Fatal(err)
+	}
+	l := x.([]interface{})
+	if len(l) != n+1 || len(l[n].([]interface{})) != 2 {
+		t.Fatalf("got %d levels", len(l))
+	}
+	var y tree
+	if err := Unmarshal(b, &y); err != nil || len(y) != n+1 || len(y[n][0][1]) != 2 {
+		t.Fatalf("typed: %v", err)
+	}
+	var z encoding.Node
+	if err := Unmarshal(b, &z); err != nil || z.List[n].List[0] != z.List[n-1] {
+		t.Fatalf("node: %v", err)
+	}
+}
+
+type pt struct {
+	Class string  `amf:"$"`
+	A     float64 `amf:"a"`
+	B     float64 `amf:"b"`
+}
+
+// 以remain字段收集成员的结构体，Class字段保留类名
+type anyPt struct {
+	Class string                 `amf:"$"`
+	Rest  map[string]interface{} `amf:",remain"`
+}
+
+func TestRemainClass(t *testing.T) {
+	for _, v := range []string{"amf0", "amf3"} {
+		b, err := Marshal(pt{"Pt", 1, 2}, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var x anyPt
+		if err = Unmarshal(b, &x); err != nil || x.Class != "Pt" || len(x.Rest) != 2 {
+			t.Fatalf("%s: got %+v, %v", v, x, err)
+		}
+		c, err := Marshal(x, v)
+		if err != nil || !bytes.Equal(c, b) {
+			t.Fatalf("%s: re-encoded as %x, want %x (%v)", v, c, b, err)
+		}
+		var y struct {
+			Rest map[string]interface{} `amf:",remain"`
+		}
+		if err = Unmarshal(b, &y); err != nil || len(y.Rest) != 2 || y.Rest["$"] != nil {
+			t.Fatalf("%s: class key collected: %v, %v", v, y.Rest, err)
+		}
+	}
+}
+
+func TestUse(t *testing.T) {
+	type timed struct {
+		D time.Duration `amf:"d"`
+	}
+	for _, c := range []struct {
+		conv []encoding.Converter
+		d    time.Duration
+		want float64
+	}{
+		{nil, 1500 * time.Millisecond, 1.5e9},
+		{[]encoding.Converter{encoding.Milliseconds}, 1500 * time.Millisecond, 1500},
+		{[]encoding.Converter{encoding.Milliseconds}, 1500 * time.Microsecond, 1.5},
+	} {
+		var w bytes.Buffer
+		e := NewEncoder(&w)
+		e.Use(c.conv...)
+		if err := e.Encode(timed{c.d}, "amf0"); err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]interface{}
+		if err := Unmarshal(w.Bytes(), &m); err != nil || m["d"] != c.want {
+			t.Errorf("%v: encoded %v, %v; want %v", c.d, m, err, c.want)
+		}
+		d := NewDecoder(&w)
+		d.Use(c.conv...)
+		var y timed
+		if err := d.Decode(&y); err != nil || y.D != c.d {
+			t.Errorf("%v: decoded %v, %v", c.d, y.D, err)
+		}
+	}
+}
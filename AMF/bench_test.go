@@ -0,0 +1,53 @@
+package AMF
+
+import (
+	"testing"
+	"time"
+)
+
+type benchItem struct {
+	Class string            `amf:"$"`
+	ID    int               `amf:"id"`
+	Name  string            `amf:"name"`
+	Tags  []string          `amf:"tags"`
+	Attr  map[string]string `amf:"attr"`
+	Date  time.Time         `amf:"date"`
+}
+
+func benchItems() []benchItem {
+	x := make([]benchItem, 32)
+	for i := range x {
+		x[i] = benchItem{"Item", i, "item", []string{"a", "b", "c"}, map[string]string{"k": "v"}, time.Unix(1500000000, 0)}
+	}
+	return x
+}
+
+func benchmarkEncode(b *testing.B, s string) {
+	x := benchItems()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, e := Marshal(x, s); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
+
+func benchmarkDecode(b *testing.B, s string) {
+	d, e := Marshal(benchItems(), s)
+	if e != nil {
+		b.Fatal(e)
+	}
+	b.SetBytes(int64(len(d)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var x []benchItem
+		if e = Unmarshal(d, &x); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
+
+func BenchmarkEncodeAMF0(b *testing.B) { benchmarkEncode(b, "amf0") }
+func BenchmarkEncodeAMF3(b *testing.B) { benchmarkEncode(b, "amf3") }
+func BenchmarkDecodeAMF0(b *testing.B) { benchmarkDecode(b, "amf0") }
+func BenchmarkDecodeAMF3(b *testing.B) { benchmarkDecode(b, "amf3") }
@@ -1,319 +1,580 @@
 package AMF
 
 import (
+	"encoding/binary"
 	"github.com/hydra13142/encoding"
+	"io"
 	"math"
 	"time"
-	"unsafe"
 )
 
-// 解码器
+// 解码器，通过内嵌的Iterator提供InputOffset、Peek、Discard和Buffered方法；
+// 每个值使用各自的引用表，Obj、Str和Tra在读取下一个值时清空
 type Decoder struct {
 	*encoding.Iterator
-	Obj []interface{}
-	Str []string
-	Tra [][]string
+	Obj  []interface{}
+	Str  []string
+	Tra  [][]string
+	AMF3 bool // 为真时按AMF3读取值，否则按AMF0读取
+
+	dyn    []bool // Tra中各特征是否为动态对象
+	strict bool
+	legacy bool // AMF3日期之后还有旧版本编码器写入的两个字节
+	limits encoding.Limits
+	start  int64                // 当前顶层值的起始偏移
+	depth  int                  // 当前的嵌套深度
+	conv   *encoding.Translator // 调用Use后使用的Translator，为nil时使用包的默认设置
+}
+
+// 生成格式错误，其位置为最后读取的字节
+func (this *Decoder) syntax(msg string) error {
+	return &encoding.FormatError{Offset: this.InputOffset() - 1, Msg: msg}
+}
+
+// 预留对象引用表的位置，复杂对象在读取其成员之前即获得索引
+func (this *Decoder) reserve() int {
+	this.Obj = append(this.Obj, nil)
+	return len(this.Obj) - 1
 }
 
-func (this *Decoder) float() (x float64) {
-	s := this.ReadBytes(8)
-	p := uintptr(unsafe.Pointer(&x))
-	for i := 0; i < 8; i++ {
-		*(*byte)(unsafe.Pointer(p + uintptr(i))) = s[7-i]
+// 获取对象引用表中的对象，尚未读取完成的对象（即循环引用）为空值
+func (this *Decoder) object(i int) (*encoding.Node, error) {
+	if i >= len(this.Obj) {
+		return nil, this.syntax("reference out of range")
+	}
+	if n, ok := this.Obj[i].(*encoding.Node); ok {
+		return n, nil
 	}
-	return x
+	return &encoding.Node{Kind: encoding.NullNode}, nil
 }
 
-func (this *Decoder) short() uint {
-	a := this.ReadByte()
-	b := this.ReadByte()
-	return (uint(a) << 8) + uint(b)
+// 添加对象的成员，重复的键以后出现的值为准
+func member(obj *encoding.Node, seen map[string]int, key string, vlu *encoding.Node) {
+	if i, ok := seen[key]; ok {
+		obj.Dict[i].Value = vlu
+		return
+	}
+	seen[key] = len(obj.Dict)
+	obj.Dict = append(obj.Dict, encoding.Field{Key: key, Value: vlu})
 }
 
-func (this *Decoder) long() uint {
-	a := this.short()
-	b := this.short()
-	return (a << 16) + b
+// 生成超出资源限制的错误
+func (this *Decoder) limit(e error) error {
+	return &encoding.LimitError{Offset: this.InputOffset(), Err: e}
 }
 
-func (this *Decoder) uint29() (s uint) {
+// 检查成员数
+func (this *Decoder) length(n int) error {
+	if e := this.limits.Length(n); e != nil {
+		return this.limit(e)
+	}
+	return nil
+}
+
+// 读取值中间的一个字节，输入结束时返回io.ErrUnexpectedEOF
+func (this *Decoder) next() (byte, error) {
+	if e := this.limits.Bytes(this.InputOffset() - this.start + 1); e != nil {
+		return 0, this.limit(e)
+	}
+	c, e := this.ReadByte()
+	if e == io.EOF {
+		e = io.ErrUnexpectedEOF
+	}
+	return c, e
+}
+
+// 读取值中间的n个字节，输入结束时返回io.ErrUnexpectedEOF
+func (this *Decoder) read(n int) ([]byte, error) {
+	if e := this.limits.Bytes(this.InputOffset() - this.start + int64(n)); e != nil {
+		return nil, this.limit(e)
+	}
+	s, e := this.ReadN(n)
+	if e == io.EOF {
+		e = io.ErrUnexpectedEOF
+	}
+	return s, e
+}
+
+// 读取长度为n的字符串或字节串
+func (this *Decoder) text(n int) ([]byte, error) {
+	if e := this.limits.String(n); e != nil {
+		return nil, this.limit(e)
+	}
+	return this.read(n)
+}
+
+func (this *Decoder) float() (float64, error) {
+	s, e := this.read(8)
+	if e != nil {
+		return 0, e
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(s)), nil
+}
+
+func (this *Decoder) short() (uint, error) {
+	s, e := this.read(2)
+	if e != nil {
+		return 0, e
+	}
+	return uint(binary.BigEndian.Uint16(s)), nil
+}
+
+func (this *Decoder) long() (uint, error) {
+	s, e := this.read(4)
+	if e != nil {
+		return 0, e
+	}
+	return uint(binary.BigEndian.Uint32(s)), nil
+}
+
+func (this *Decoder) uint29() (s uint, e error) {
+	var x byte
 	for i := 0; i < 3; i++ {
-		x := this.ReadByte()
+		if x, e = this.next(); e != nil {
+			return 0, e
+		}
 		if x&128 == 0 {
-			return (s << 7) + uint(x)
+			return (s << 7) + uint(x), nil
 		}
 		s = (s << 7) + (uint(x) & 127)
 	}
-	return s<<8 + uint(this.ReadByte())
+	if x, e = this.next(); e != nil {
+		return 0, e
+	}
+	return s<<8 + uint(x), nil
 }
 
-func (this *Decoder) int29() int64 {
-	return int64((int32(this.uint29()) << 3) >> 3)
+func (this *Decoder) int29() (int64, error) {
+	s, e := this.uint29()
+	return int64((int32(s) << 3) >> 3), e
 }
 
-func (this *Decoder) bytes() string {
-	l := int(this.short())
-	return string(this.ReadBytes(l))
+func (this *Decoder) bytes() (string, error) {
+	l, e := this.short()
+	if e != nil {
+		return "", e
+	}
+	s, e := this.text(int(l))
+	return string(s), e
 }
 
-func (this *Decoder) utf8() string {
-	s := int(this.uint29())
+func (this *Decoder) utf8() (string, error) {
+	s, e := this.uint29()
+	if e != nil {
+		return "", e
+	}
 	p, s := s&1, s>>1
 	if p == 0 {
-		return this.Str[s]
+		if int(s) >= len(this.Str) {
+			return "", this.syntax("string reference out of range")
+		}
+		return this.Str[s], nil
 	}
 	if s == 0 {
-		return ""
+		return "", nil
+	}
+	b, e := this.text(int(s))
+	if e != nil {
+		return "", e
 	}
-	str := string(this.ReadBytes(s))
+	str := string(b)
 	this.Str = append(this.Str, str)
-	return str
+	return str, nil
 }
 
-func (this *Decoder) decodeAMF0() (it interface{}, er error) {
-	defer func() {
-		if e := recover(); e != nil {
-			it, er = nil, e.(error)
+// 读取AMF0对象的成员，直至空键和结束标记
+func (this *Decoder) members(obj *encoding.Node) error {
+	seen := map[string]int{}
+	for i := 1; ; i++ {
+		key, e := this.bytes()
+		if e != nil {
+			return e
 		}
-	}()
-	switch this.ReadByte() {
+		if key == "" {
+			c, e := this.next()
+			if e != nil {
+				return e
+			}
+			if c != 0x09 {
+				return this.syntax("missing object end marker")
+			}
+			return nil
+		}
+		if e = this.length(i); e != nil {
+			return e
+		}
+		vlu, e := this.value0()
+		if e != nil {
+			return e
+		}
+		member(obj, seen, key, vlu)
+	}
+}
+
+// 读取并解码一个AMF0的值，输入在值开始前结束时返回io.EOF
+func (this *Decoder) decodeAMF0() (*encoding.Node, error) {
+	this.start, this.depth = this.InputOffset(), 0
+	c, e := this.ReadByte()
+	if e != nil {
+		return nil, e
+	}
+	return this.amf0(c)
+}
+
+// 读取并解码值中间的一个AMF0的值
+func (this *Decoder) value0() (*encoding.Node, error) {
+	this.depth++
+	defer func() { this.depth-- }()
+	if e := this.limits.Depth(this.depth); e != nil {
+		return nil, this.limit(e)
+	}
+	c, e := this.next()
+	if e != nil {
+		return nil, e
+	}
+	return this.amf0(c)
+}
+
+// 解码一个AMF0的值，c为已读取的类型标记
+func (this *Decoder) amf0(c byte) (*encoding.Node, error) {
+	o := this.InputOffset() - 1
+	switch c {
 	case 0x00: // float64
-		return this.float(), nil
+		f, e := this.float()
+		return &encoding.Node{Kind: encoding.FloatNode, Float: f, Offset: o}, e
 	case 0x01: // boolean
-		return this.ReadByte() != 0, nil
+		c, e := this.next()
+		return &encoding.Node{Kind: encoding.BoolNode, Bool: c != 0, Offset: o}, e
 	case 0x05: // null
-		return nil, nil
+		return &encoding.Node{Kind: encoding.NullNode, Offset: o}, nil
 	case 0x06: // undefined
-		return encoding.Undefined{}, nil
+		return &encoding.Node{Kind: encoding.UndefinedNode, Offset: o}, nil
 	case 0x02: // string
-		return this.bytes(), nil
+		s, e := this.bytes()
+		return &encoding.Node{Kind: encoding.StringNode, Str: s, Offset: o}, e
 	case 0x0c: // long string
-		l := int(this.long())
-		return string(this.ReadBytes(l)), nil
+		l, e := this.long()
+		if e != nil {
+			return nil, e
+		}
+		s, e := this.text(int(l))
+		return &encoding.Node{Kind: encoding.StringNode, Str: string(s), Offset: o}, e
 	case 0x0f: // XML document
-		l := int(this.long())
-		return XML(this.ReadBytes(l)), nil
+		l, e := this.long()
+		if e != nil {
+			return nil, e
+		}
+		s, e := this.text(int(l))
+		return &encoding.Node{Kind: encoding.RawNode, Raw: XML(s), Offset: o}, e
 	case 0x0b: // date
-		i, f := math.Modf(this.float() / 1000)
-		offset := time.Duration(this.short() * 3600)
-		return time.Unix(int64(i), int64(f*1e9)).Add(offset), nil
+		f, e := this.float()
+		if e != nil {
+			return nil, e
+		}
+		// 时区字段保留不用，毫秒数总是UTC时间
+		if _, e = this.short(); e != nil {
+			return nil, e
+		}
+		i, f := math.Modf(f / 1000)
+		return &encoding.Node{Kind: encoding.RawNode, Raw: time.Unix(int64(i), int64(f*1e9)), Offset: o}, nil
 	case 0x07: // reference
-		l := int(this.short())
-		if l != len(this.Obj) {
-			return nil, encoding.SyntaxError
+		l, e := this.short()
+		if e != nil {
+			return nil, e
 		}
-		return this.Obj[l], nil
+		return this.object(int(l))
 	case 0x0a: // strict array
-		arr := []interface{}{}
-		l := int(this.long())
-		for i := 0; i < l; i++ {
-			vlu, err := this.decodeAMF0()
-			if err != nil {
-				return nil, err
+		n := this.reserve()
+		l, e := this.long()
+		if e == nil {
+			e = this.length(int(l))
+		}
+		if e != nil {
+			return nil, e
+		}
+		arr := &encoding.Node{Kind: encoding.ListNode, List: []*encoding.Node{}, Offset: o}
+		for i := 0; i < int(l); i++ {
+			vlu, e := this.value0()
+			if e != nil {
+				return nil, e
 			}
-			arr = append(arr, vlu)
+			arr.List = append(arr.List, vlu)
 		}
-		this.Obj = append(this.Obj, arr)
+		this.Obj[n] = arr
 		return arr, nil
 	case 0x08: // ECMA array
-		obj := make(map[string]interface{})
-		l := int(this.long())
-		for i := 0; i < l; i++ {
-			key := this.bytes()
-			vlu, err := this.decodeAMF0()
-			if err != nil {
-				return nil, err
+		n := this.reserve()
+		l, e := this.long()
+		if e == nil {
+			e = this.length(int(l))
+		}
+		if e != nil {
+			return nil, e
+		}
+		obj := &encoding.Node{Kind: encoding.DictNode, Dict: []encoding.Field{}, Offset: o}
+		seen := map[string]int{}
+		for i := 0; i < int(l); i++ {
+			key, e := this.bytes()
+			if e != nil {
+				return nil, e
 			}
-			obj[key] = vlu
+			vlu, e := this.value0()
+			if e != nil {
+				return nil, e
+			}
+			member(obj, seen, key, vlu)
 		}
-		this.Obj = append(this.Obj, obj)
+		this.Obj[n] = obj
 		return obj, nil
 	case 0x03: // object
-		obj := make(map[string]interface{})
-		obj["$"] = ""
-		for {
-			key := this.bytes()
-			if key == "" {
-				if this.ReadByte() != 0x09 {
-					return nil, encoding.SyntaxError
-				}
-				break
-			}
-			vlu, err := this.decodeAMF0()
-			if err != nil {
-				return nil, err
-			}
-			obj[key] = vlu
+		n := this.reserve()
+		obj := &encoding.Node{Kind: encoding.ObjectNode, Dict: []encoding.Field{}, Offset: o}
+		if e := this.members(obj); e != nil {
+			return nil, e
 		}
-		this.Obj = append(this.Obj, obj)
+		this.Obj[n] = obj
 		return obj, nil
 	case 0x10: // typed object
-		obj := make(map[string]interface{})
-		obj["$"] = this.bytes()
-		for {
-			key := this.bytes()
-			if key == "" {
-				if this.ReadByte() != 0x09 {
-					return nil, encoding.SyntaxError
-				}
-				break
-			}
-			vlu, err := this.decodeAMF0()
-			if err != nil {
-				return nil, err
-			}
-			obj[key] = vlu
+		n := this.reserve()
+		name, e := this.bytes()
+		if e != nil {
+			return nil, e
+		}
+		obj := &encoding.Node{Kind: encoding.ObjectNode, Str: name, Dict: []encoding.Field{}, Offset: o}
+		if e := this.members(obj); e != nil {
+			return nil, e
 		}
-		this.Obj = append(this.Obj, obj)
+		this.Obj[n] = obj
 		return obj, nil
 	case 0x11: // amf3
-		return this.decodeAMF3()
+		return this.value3()
 	}
 	return nil, encoding.UnsupportType
 }
 
-func (this *Decoder) decodeAMF3() (it interface{}, er error) {
-	defer func() {
-		if e := recover(); e != nil {
-			it, er = nil, e.(error)
+// 读取并解码一个AMF3的值，输入在值开始前结束时返回io.EOF
+//
+// 值前的0x11（AMF0中切换到AMF3的标记，Encode按AMF3编码时写出）会被跳过
+func (this *Decoder) decodeAMF3() (*encoding.Node, error) {
+	this.start, this.depth = this.InputOffset(), 0
+	c, e := this.ReadByte()
+	if e != nil {
+		return nil, e
+	}
+	if c == 0x11 {
+		if c, e = this.next(); e != nil {
+			return nil, e
 		}
-	}()
-	switch this.ReadByte() {
+	}
+	return this.amf3(c)
+}
+
+// 按AMF3字段选择的版本读取并解码一个值
+func (this *Decoder) decode() (*encoding.Node, error) {
+	this.Obj, this.Str, this.Tra, this.dyn = nil, nil, nil, nil
+	if this.AMF3 {
+		return this.decodeAMF3()
+	}
+	return this.decodeAMF0()
+}
+
+// 读取并解码值中间的一个AMF3的值
+func (this *Decoder) value3() (*encoding.Node, error) {
+	this.depth++
+	defer func() { this.depth-- }()
+	if e := this.limits.Depth(this.depth); e != nil {
+		return nil, this.limit(e)
+	}
+	c, e := this.next()
+	if e != nil {
+		return nil, e
+	}
+	return this.amf3(c)
+}
+
+// 读取AMF3的引用或内联长度，内联时返回true
+func (this *Decoder) inline() (int, bool, error) {
+	s, e := this.uint29()
+	return int(s >> 1), s&1 != 0, e
+}
+
+// 解码一个AMF3的值，c为已读取的类型标记
+func (this *Decoder) amf3(c byte) (*encoding.Node, error) {
+	o := this.InputOffset() - 1
+	switch c {
 	case 0x00: // undefined
-		return encoding.Undefined{}, nil
+		return &encoding.Node{Kind: encoding.UndefinedNode, Offset: o}, nil
 	case 0x01: // null
-		return nil, nil
+		return &encoding.Node{Kind: encoding.NullNode, Offset: o}, nil
 	case 0x02: // false
-		return false, nil
+		return &encoding.Node{Kind: encoding.BoolNode, Bool: false, Offset: o}, nil
 	case 0x03: // true
-		return true, nil
+		return &encoding.Node{Kind: encoding.BoolNode, Bool: true, Offset: o}, nil
 	case 0x04: // int
-		return this.int29(), nil
+		i, e := this.int29()
+		return &encoding.Node{Kind: encoding.IntNode, Int: i, Offset: o}, e
 	case 0x05: // float
-		return this.float(), nil
+		f, e := this.float()
+		return &encoding.Node{Kind: encoding.FloatNode, Float: f, Offset: o}, e
 	case 0x06: // string
-		return this.utf8(), nil
-	case 0x0c: // byte-array
-		s := int(this.uint29())
-		if p, s := s&1, s>>1; p == 0 {
-			return this.Obj[s], nil
+		s, e := this.utf8()
+		return &encoding.Node{Kind: encoding.StringNode, Str: s, Offset: o}, e
+	case 0x0c, 0x07, 0x0b: // byte-array、xml-doc、xml
+		s, p, e := this.inline()
+		if e != nil {
+			return nil, e
 		}
-		str := this.ReadBytes(s)
-		this.Obj = append(this.Obj, str)
-		return str, nil
-	case 0x07: // xml-doc
-		s := int(this.uint29())
-		p, s := s&1, s>>1
-		if p == 0 {
-			return this.Obj[s], nil
+		if !p {
+			return this.object(s)
 		}
-		str := XML(this.ReadBytes(s))
-		this.Obj = append(this.Obj, str)
-		return str, nil
-	case 0x0b: // xml
-		s := int(this.uint29())
-		p, s := s&1, s>>1
-		if p == 0 {
-			return this.Obj[s], nil
+		b, e := this.text(s)
+		if e != nil {
+			return nil, e
+		}
+		str := &encoding.Node{Kind: encoding.BytesNode, Bytes: b, Offset: o}
+		if c == 0x07 {
+			str = &encoding.Node{Kind: encoding.RawNode, Raw: XML(b), Offset: o}
+		} else if c == 0x0b {
+			str = &encoding.Node{Kind: encoding.RawNode, Raw: E4X(b), Offset: o}
 		}
-		str := E4X(this.ReadBytes(s))
 		this.Obj = append(this.Obj, str)
 		return str, nil
 	case 0x08: // date
-		s := this.uint29()
-		p, s := s&1, s>>1
-		if p == 0 {
-			return this.Obj[s], nil
-		}
-		i, f := math.Modf(this.float() / 1000)
-		offset := time.Duration(this.short() * 3600)
-		return time.Unix(int64(i), int64(f*1e9)).Add(offset), nil
+		s, p, e := this.inline()
+		if e != nil {
+			return nil, e
+		}
+		if !p {
+			return this.object(s)
+		}
+		f, e := this.float()
+		if e != nil {
+			return nil, e
+		}
+		if this.legacy {
+			if _, e = this.short(); e != nil {
+				return nil, e
+			}
+		}
+		i, f := math.Modf(f / 1000)
+		date := &encoding.Node{Kind: encoding.RawNode, Raw: time.Unix(int64(i), int64(f*1e9)), Offset: o}
+		this.Obj = append(this.Obj, date)
+		return date, nil
 	case 0x09: // array
-		s := this.uint29()
-		p, s := s&1, s>>1
-		if p == 0 {
-			return this.Obj[s], nil
+		s, p, e := this.inline()
+		if e != nil {
+			return nil, e
+		}
+		if !p {
+			return this.object(s)
+		}
+		n := this.reserve()
+		key, e := this.utf8()
+		if e != nil {
+			return nil, e
 		}
 		if s == 0 {
-			arr := make(map[string]interface{})
-			for {
-				key := this.utf8()
-				if key == "" {
-					break
+			arr := &encoding.Node{Kind: encoding.DictNode, Dict: []encoding.Field{}, Offset: o}
+			seen := map[string]int{}
+			for key != "" {
+				if e = this.length(len(arr.Dict) + 1); e != nil {
+					return nil, e
+				}
+				vlu, e := this.value3()
+				if e != nil {
+					return nil, e
 				}
-				vlu, err := this.decodeAMF3()
-				if err != nil {
-					return nil, err
+				member(arr, seen, key, vlu)
+				if key, e = this.utf8(); e != nil {
+					return nil, e
 				}
-				arr[key] = vlu
 			}
-			this.Obj = append(this.Obj, arr)
+			this.Obj[n] = arr
 			return arr, nil
-		} else if this.utf8() == "" {
-			arr := make([]interface{}, 0)
-			for i := 0; i < int(s); i++ {
-				vlu, err := this.decodeAMF3()
-				if err != nil {
-					return nil, err
+		} else if key == "" {
+			if e = this.length(s); e != nil {
+				return nil, e
+			}
+			arr := &encoding.Node{Kind: encoding.ListNode, List: []*encoding.Node{}, Offset: o}
+			for i := 0; i < s; i++ {
+				vlu, e := this.value3()
+				if e != nil {
+					return nil, e
 				}
-				arr = append(arr, vlu)
+				arr.List = append(arr.List, vlu)
 			}
-			this.Obj = append(this.Obj, arr)
+			this.Obj[n] = arr
 			return arr, nil
 		}
 		return nil, encoding.UnsupportType
 	case 0x0a: // object
-		t := int(this.uint29())
+		s, e := this.uint29()
+		if e != nil {
+			return nil, e
+		}
+		t := int(s)
 		if t&1 == 0 {
-			return this.Obj[t>>1], nil
-		}
-		if t&2 == 0 {
-			tra := this.Tra[t>>2]
-			obj := make(map[string]interface{})
-			obj["$"] = tra[0]
-			for i := 1; i < len(tra); i++ {
-				vlu, err := this.decodeAMF3()
-				if err != nil {
-					return nil, err
-				}
-				obj[tra[i]] = vlu
-			}
-			this.Obj = append(this.Obj, obj)
-			return obj, nil
+			return this.object(t >> 1)
 		}
-		if t&4 == 0 {
-			tra := []string{}
+		n := this.reserve()
+		var (
+			tra []string
+			dyn = t&8 != 0
+		)
+		switch {
+		case t&2 == 0:
+			if t>>2 >= len(this.Tra) {
+				return nil, this.syntax("traits reference out of range")
+			}
+			tra = this.Tra[t>>2]
+			dyn = t>>2 < len(this.dyn) && this.dyn[t>>2]
+		case t&4 == 0:
 			l := (t >> 4) + 1
-			for i := 0; i < l; i++ {
-				tra = append(tra, this.utf8())
+			if e = this.length(l - 1); e != nil {
+				return nil, e
 			}
-			obj := make(map[string]interface{})
-			obj["$"] = tra[0]
-			for i := 1; i < l; i++ {
-				vlu, err := this.decodeAMF3()
-				if err != nil {
-					return nil, err
+			for i := 0; i < l; i++ {
+				key, e := this.utf8()
+				if e != nil {
+					return nil, e
 				}
-				obj[tra[i]] = vlu
+				tra = append(tra, key)
 			}
 			this.Tra = append(this.Tra, tra)
-			if t&8 == 1 {
-				for {
-					key := this.utf8()
-					if key == "" {
-						break
-					}
-					vlu, err := this.decodeAMF3()
-					if err != nil {
-						return nil, err
-					}
-					obj["@"+key] = vlu
+			this.dyn = append(this.dyn, dyn)
+		default:
+			return nil, encoding.UnsupportType
+		}
+		obj := &encoding.Node{Kind: encoding.ObjectNode, Str: tra[0], Dict: []encoding.Field{}, Offset: o}
+		seen := map[string]int{}
+		for i := 1; i < len(tra); i++ {
+			vlu, e := this.value3()
+			if e != nil {
+				return nil, e
+			}
+			member(obj, seen, tra[i], vlu)
+		}
+		if dyn {
+			for i := len(tra); ; i++ {
+				key, e := this.utf8()
+				if e != nil {
+					return nil, e
 				}
+				if key == "" {
+					break
+				}
+				if e = this.length(i); e != nil {
+					return nil, e
+				}
+				vlu, e := this.value3()
+				if e != nil {
+					return nil, e
+				}
+				member(obj, seen, "@"+key, vlu)
 			}
-			this.Obj = append(this.Obj, obj)
-			return obj, nil
 		}
-		return nil, encoding.UnsupportType
+		this.Obj[n] = obj
+		return obj, nil
 	}
 	return nil, encoding.UnsupportType
 }
@@ -1,24 +1,51 @@
 package AMF
 
 import (
+	"encoding/binary"
 	"github.com/hydra13142/encoding"
 	"io"
+	"math"
 	"time"
-	"unsafe"
 )
 
-// 编码器
+// 编码器，每个值使用各自的引用表，Refer写出的引用从该值的第一个对象起计数
 type Encoder struct {
 	io.Writer
-	Str map[string]int
+	Str   map[string]int
+	Refer bool // 为真时值的循环引用编码为对象引用，否则返回错误
+	AMF3  bool // 为真时EncodeNode按AMF3编码，否则按AMF0编码
+	obj   int  // 已写入对象引用表的对象数
+	ref   []refer
+	keep  bool                 // omitempty只忽略nil的slice和map
+	conv  *encoding.Translator // 调用Use后使用的Translator，为nil时使用包的默认设置
+}
+
+// 开始编码一个新的值，清空上一个值的引用表
+func (this *Encoder) reset() {
+	this.obj, this.ref = 0, this.ref[:0]
+	this.Str = make(map[string]int)
+}
+
+// 当前路径上的一个复杂对象
+type refer struct {
+	n int  // 在对象引用表中的索引
+	m byte // 类型标记
+}
+
+// 为复杂对象分配引用表索引并进入该对象
+func (this *Encoder) enter(m byte) {
+	this.ref = append(this.ref, refer{this.obj, m})
+	this.obj++
+}
+
+// 离开当前的复杂对象
+func (this *Encoder) leave() {
+	this.ref = this.ref[:len(this.ref)-1]
 }
 
 func (this *Encoder) float(x float64) {
 	s := make([]byte, 8)
-	p := uintptr(unsafe.Pointer(&x))
-	for i := 0; i < 8; i++ {
-		s[7-i] = *(*byte)(unsafe.Pointer(p + uintptr(i)))
-	}
+	binary.BigEndian.PutUint64(s, math.Float64bits(x))
 	this.Write(s)
 }
 
@@ -31,6 +58,10 @@ func (this *Encoder) long(i uint) {
 }
 
 func (this *Encoder) bytes(s string) {
+	if s == "" {
+		this.uint29(1)
+		return
+	}
 	i, ok := this.Str[s]
 	if ok {
 		this.uint29(uint(i<<1) | 0)
@@ -42,6 +73,13 @@ func (this *Encoder) bytes(s string) {
 	}
 }
 
+// 写入不使用引用的字节序列，并占用对象引用表的一个位置
+func (this *Encoder) inline(s []byte) {
+	this.obj++
+	this.uint29(uint(len(s)<<1) | 1)
+	this.Write(s)
+}
+
 func (this *Encoder) uint29(i uint) {
 	switch {
 	case i>>21 != 0:
@@ -61,6 +99,8 @@ func (this *Encoder) encodeAMF0(x interface{}) error {
 		return nil
 	}
 	switch x.(type) {
+	case encoding.Undefined:
+		this.Write([]byte{0x06})
 	case bool:
 		if x.(bool) {
 			this.Write([]byte{0x01, 0x01})
@@ -70,6 +110,12 @@ func (this *Encoder) encodeAMF0(x interface{}) error {
 	case float64:
 		this.Write([]byte{0x00})
 		this.float(x.(float64))
+	case int64:
+		this.Write([]byte{0x00})
+		this.float(float64(x.(int64)))
+	case uint64:
+		this.Write([]byte{0x00})
+		this.float(float64(x.(uint64)))
 	case string:
 		s := x.(string)
 		l := uint(len(s))
@@ -91,9 +137,18 @@ func (this *Encoder) encodeAMF0(x interface{}) error {
 		this.Write([]byte{0x0b})
 		this.float(float64(x.(time.Time).UnixNano()) / 1e6)
 		this.Write([]byte{0, 0})
+	case encoding.Reference:
+		r := x.(encoding.Reference)
+		if r.N >= len(this.ref) {
+			return encoding.UnsupportType
+		}
+		this.Write([]byte{0x07})
+		this.short(uint(this.ref[r.N].n))
 	case []interface{}:
 		d := x.([]interface{})
 		l := len(d)
+		this.enter(0x0a)
+		defer this.leave()
 		this.Write([]byte{0x0a})
 		this.long(uint(l))
 		for i := 0; i < l; i++ {
@@ -105,13 +160,15 @@ func (this *Encoder) encodeAMF0(x interface{}) error {
 	case []encoding.Item:
 		d := x.([]encoding.Item)
 		l := len(d)
-		if _, ok := d[0].K.(string); !ok {
-			return encoding.UnsupportType
-		}
+		this.enter(0x08)
+		defer this.leave()
 		this.Write([]byte{0x08})
 		this.long(uint(l))
 		for i := 0; i < l; i++ {
-			s := d[i].K.(string)
+			s, ok := d[i].K.(string)
+			if !ok {
+				return encoding.UnsupportType
+			}
 			this.short(uint(len(s)))
 			this.Write([]byte(s))
 			err := this.encodeAMF0(d[i].V)
@@ -123,17 +180,20 @@ func (this *Encoder) encodeAMF0(x interface{}) error {
 		name := ""
 		d := x.([]encoding.Attr)
 		l := len(d)
-		if d[0].K == "$" {
+		if len(d) > 0 && d[0].K == "$" {
 			var ok bool
 			name, ok = d[0].V.(string)
 			if ok {
 				l, d = l-1, d[1:]
 			}
 		}
+		this.enter(0x03)
+		defer this.leave()
 		if name == "" {
 			this.Write([]byte{0x03})
 		} else {
 			this.Write([]byte{0x10})
+			this.short(uint(len(name)))
 			this.Write([]byte(name))
 		}
 		for i := 0; i < l; i++ {
@@ -145,6 +205,8 @@ func (this *Encoder) encodeAMF0(x interface{}) error {
 			}
 		}
 		this.Write([]byte{0x00, 0x00, 0x09})
+	case *encoding.Node:
+		return this.encodeAMF0(x.(*encoding.Node).Value("$"))
 	default:
 		return encoding.UnsupportType
 	}
@@ -157,6 +219,8 @@ func (this *Encoder) encodeAMF3(x interface{}) error {
 		return nil
 	}
 	switch x.(type) {
+	case encoding.Undefined:
+		this.Write([]byte{0x00})
 	case bool:
 		if x.(bool) {
 			this.Write([]byte{0x03})
@@ -164,8 +228,23 @@ func (this *Encoder) encodeAMF3(x interface{}) error {
 			this.Write([]byte{0x02})
 		}
 	case int64:
-		this.Write([]byte{0x04})
-		this.uint29(uint(x.(int64)))
+		i := x.(int64)
+		if i < -1<<28 || i >= 1<<28 {
+			this.Write([]byte{0x05})
+			this.float(float64(i))
+		} else {
+			this.Write([]byte{0x04})
+			this.uint29(uint(i) & 0x1fffffff)
+		}
+	case uint64:
+		i := x.(uint64)
+		if i >= 1<<28 {
+			this.Write([]byte{0x05})
+			this.float(float64(i))
+		} else {
+			this.Write([]byte{0x04})
+			this.uint29(uint(i))
+		}
 	case float64:
 		this.Write([]byte{0x05})
 		this.float(x.(float64))
@@ -175,21 +254,27 @@ func (this *Encoder) encodeAMF3(x interface{}) error {
 		this.bytes(s)
 	case []byte:
 		this.Write([]byte{0x0c})
-		s := x.([]byte)
-		this.bytes(string(s))
+		this.inline(x.([]byte))
 	case XML:
 		this.Write([]byte{0x07})
-		s := x.(XML)
-		this.bytes(string(s))
+		this.inline(x.(XML))
 	case E4X:
 		this.Write([]byte{0x0b})
-		s := x.(E4X)
-		this.bytes(string(s))
+		this.inline(x.(E4X))
 	case time.Time:
+		this.obj++
 		this.Write([]byte{0x08, 0x01})
 		this.float(float64(x.(time.Time).UnixNano()) / 1e6)
-		this.Write([]byte{0, 0})
+	case encoding.Reference:
+		r := x.(encoding.Reference)
+		if r.N >= len(this.ref) {
+			return encoding.UnsupportType
+		}
+		this.Write([]byte{this.ref[r.N].m})
+		this.uint29(uint(this.ref[r.N].n<<1) | 0)
 	case []interface{}:
+		this.enter(0x09)
+		defer this.leave()
 		this.Write([]byte{0x09})
 		d := x.([]interface{})
 		l := len(d)
@@ -202,14 +287,17 @@ func (this *Encoder) encodeAMF3(x interface{}) error {
 			}
 		}
 	case []encoding.Item:
+		this.enter(0x09)
+		defer this.leave()
 		this.Write([]byte{0x09, 0x01})
 		d := x.([]encoding.Item)
 		l := len(d)
-		if _, ok := d[0].K.(string); !ok {
-			return encoding.UnsupportType
-		}
 		for i := 0; i < l; i++ {
-			this.bytes(d[i].K.(string))
+			s, ok := d[i].K.(string)
+			if !ok {
+				return encoding.UnsupportType
+			}
+			this.bytes(s)
 			err := this.encodeAMF3(d[i].V)
 			if err != nil {
 				return err
@@ -217,10 +305,12 @@ func (this *Encoder) encodeAMF3(x interface{}) error {
 		}
 		this.Write([]byte{0x01})
 	case []encoding.Attr:
+		this.enter(0x0a)
+		defer this.leave()
 		this.Write([]byte{0x0a})
 		name := ""
 		d := x.([]encoding.Attr)
-		if d[0].K == "$" {
+		if len(d) > 0 && d[0].K == "$" {
 			var ok bool
 			name, ok = d[0].V.(string)
 			if ok {
@@ -257,6 +347,8 @@ func (this *Encoder) encodeAMF3(x interface{}) error {
 				return err
 			}
 		}
+	case *encoding.Node:
+		return this.encodeAMF3(x.(*encoding.Node).Value("$"))
 	default:
 		return encoding.UnsupportType
 	}
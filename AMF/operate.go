@@ -1,6 +1,7 @@
 package AMF
 
 import (
+	"bytes"
 	"errors"
 	"github.com/hydra13142/encoding"
 	"io"
@@ -17,23 +18,78 @@ var rawtype = map[reflect.Type]struct{}{
 	reflect.TypeOf(time.Unix(0, 0)): struct{}{},
 }
 
-var translator = encoding.Translator{"amf", make(map[reflect.Type][]encoding.Label), rawtype}
+var translator = newTranslator()
+
+func newTranslator() *encoding.Translator {
+	t := encoding.NewTranslator("amf", rawtype)
+	t.Class = "$"
+	t.Use(encoding.BigInteger, encoding.IPAddress, encoding.URLString)
+	return t
+}
+
+// 在t上注册转换器，t为nil时先创建带有默认转换器的Translator
+func use(t **encoding.Translator, c []encoding.Converter) {
+	if *t == nil {
+		*t = newTranslator()
+	}
+	(*t).Use(c...)
+}
+
+// 返回要使用的Translator的副本，t为nil时使用包的默认设置
+func pick(t *encoding.Translator) encoding.Translator {
+	if t == nil {
+		return *translator
+	}
+	return *t
+}
 
 // 创建解码器
 func NewDecoder(r io.Reader) *Decoder {
 	return &Decoder{Iterator: encoding.NewIterator(r)}
 }
 
+// 设置解码器在对象的键没有对应的结构体字段时返回错误
+func (this *Decoder) DisallowUnknownFields() {
+	this.strict = true
+}
+
+// 设置解码器读取旧版本编码器写入的AMF3数据，其日期之后多出两个字节
+func (this *Decoder) LegacyDates() {
+	this.legacy = true
+}
+
+// 设置解码不可信输入时的资源限制，超出时返回encoding.LimitError
+func (this *Decoder) SetLimits(l encoding.Limits) {
+	this.limits = l
+}
+
 // 创建编码器
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w, make(map[string]int)}
+	return &Encoder{Writer: w, Str: make(map[string]int)}
+}
+
+// 设置编码器在omitempty字段为非nil的空slice或map时仍然编码，只忽略nil
+func (this *Encoder) KeepEmpty() {
+	this.keep = true
+}
+
+// 为该编码器注册转换器（如encoding.Milliseconds），只影响该编码器；
+// 默认启用encoding.BigInteger、IPAddress和URLString，time.Duration编码为纳秒数；
+// Encode和Decode均为nil的转换器可关闭该类型的内置转换器
+func (this *Encoder) Use(c ...encoding.Converter) {
+	use(&this.conv, c)
+}
+
+// 为该解码器注册转换器，同Encoder.Use
+func (this *Decoder) Use(c ...encoding.Converter) {
+	use(&this.conv, c)
 }
 
 // 解码并填充
 func (this *Decoder) Decode(x interface{}) error {
 	v := reflect.ValueOf(x)
 	if v.Kind() == reflect.Invalid {
-		_, e := this.decodeAMF0()
+		_, e := this.decode()
 		if e != nil {
 			return e
 		}
@@ -41,23 +97,25 @@ func (this *Decoder) Decode(x interface{}) error {
 	} else if v.Kind() != reflect.Ptr {
 		return TypeError
 	}
-	u, e := this.decodeAMF0()
+	u, e := this.decode()
 	if e != nil {
 		return e
 	}
-	return translator.Decode(v.Elem(), u)
+	t := pick(this.conv)
+	t.Strict = this.strict
+	return t.Decode(v.Elem(), u)
 }
 
 // 编码并写入
 func (this *Encoder) Encode(x interface{}, s string) error {
-	v := reflect.ValueOf(x)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-	u, e := translator.Encode(v)
+	t := pick(this.conv)
+	t.Refer = this.Refer
+	t.OnlyNil = this.keep
+	u, e := t.Encode(reflect.ValueOf(x))
 	if e != nil {
 		return e
 	}
+	this.reset()
 	switch s {
 	case "0", "amf0", "AMF0":
 		return this.encodeAMF0(u)
@@ -67,3 +125,72 @@ func (this *Encoder) Encode(x interface{}, s string) error {
 	}
 	return errors.New("codec must be AMF0 or AMF3")
 }
+
+// 编码节点并写入，版本由AMF3字段决定，该版本不能表示的节点返回encoding.LossError
+func (this *Encoder) EncodeNode(n *encoding.Node) error {
+	f := "amf0"
+	if this.AMF3 {
+		f = "amf3"
+	}
+	e := n.Check(f, func(x *encoding.Node) bool {
+		switch x.Kind {
+		case encoding.IntNode:
+			return x.Int >= -1<<53 && x.Int <= 1<<53
+		case encoding.UintNode:
+			return x.Uint <= 1<<53
+		case encoding.BytesNode:
+			return this.AMF3
+		case encoding.RawNode:
+			switch x.Raw.(type) {
+			case XML, time.Time:
+				return true
+			case E4X:
+				return this.AMF3
+			}
+			return false
+		}
+		return true
+	})
+	if e != nil {
+		return e
+	}
+	this.reset()
+	if this.AMF3 {
+		this.Write([]byte{0x11})
+		return this.encodeAMF3(n)
+	}
+	return this.encodeAMF0(n)
+}
+
+// 读取一个值并解码为节点，对象的类名为节点的Str
+func (this *Decoder) DecodeNode() (*encoding.Node, error) {
+	return this.decode()
+}
+
+// 按指定版本（AMF0或AMF3）编码对象并返回编码后的数据
+func Marshal(x interface{}, s string) ([]byte, error) {
+	w := bytes.NewBuffer(nil)
+	if e := NewEncoder(w).Encode(x, s); e != nil {
+		return nil, e
+	}
+	return w.Bytes(), nil
+}
+
+// 解码数据并填充对象，数据须恰好包含一个值
+func Unmarshal(data []byte, x interface{}) error {
+	d := NewDecoder(bytes.NewReader(data))
+	if e := d.Decode(x); e != nil {
+		return e
+	}
+	if n, _ := d.Read(make([]byte, 1)); n != 0 {
+		return &encoding.FormatError{Offset: d.InputOffset() - 1, Msg: "trailing data after value"}
+	}
+	return nil
+}
+
+// 解码数据并返回T类型的值
+func UnmarshalAs[T any](data []byte) (T, error) {
+	var x T
+	e := Unmarshal(data, &x)
+	return x, e
+}
@@ -0,0 +1,49 @@
+package bencode
+
+import (
+	"strings"
+	"testing"
+)
+
+func benchTorrent() *Torrent {
+	x := &Torrent{
+		Announce:     "http://tracker.example.com/announce",
+		AnnounceList: [][]string{{"http://tracker.example.com/announce"}, {"udp://tracker.example.org:6969"}},
+		CreateBy:     "bench",
+		CreateDate:   1500000000,
+		Info: FileInfo{
+			Name:        "bench",
+			PieceLength: 1 << 18,
+			Pieces:      strings.Repeat("\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f\x10\x11\x12\x13", 64),
+		},
+	}
+	for i := 0; i < 32; i++ {
+		x.Info.Files = append(x.Info.Files, File{Length: 1 << 20, Path: []string{"dir", strings.Repeat("f", i+1) + ".bin"}})
+	}
+	return x
+}
+
+func BenchmarkEncodeTorrent(b *testing.B) {
+	x := benchTorrent()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, e := Marshal(x); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
+
+func BenchmarkDecodeTorrent(b *testing.B) {
+	d, e := Marshal(benchTorrent())
+	if e != nil {
+		b.Fatal(e)
+	}
+	b.SetBytes(int64(len(d)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var x Torrent
+		if e = Unmarshal(d, &x); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
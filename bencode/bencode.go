@@ -1,25 +1,39 @@
 package bencode
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"math"
+	"reflect"
+	"sort"
 	"github.com/hydra13142/encoding"
 )
 
-// bencode编码器
+// bencode编码器，默认输出键按字节序排列且不重复的规范形式
 type Encoder struct {
 	io.Writer
+	legacy bool
+	keep   bool                 // omitempty只忽略nil的slice和map
+	conv   *encoding.Translator // 调用Use后使用的Translator，为nil时使用包的默认设置
 }
 
-// bencode解码器
+// bencode解码器，通过内嵌的Iterator提供InputOffset、Peek、Discard和Buffered方法
 type Decoder struct {
 	*encoding.Iterator
+	strict bool
+	canon  bool // 是否拒绝非规范形式的输入
+	limits encoding.Limits
+	start  int64                // 当前顶层值的起始偏移
+	depth  int                  // 当前的嵌套深度
+	rec    *bytes.Buffer        // 正在为RawMessage记录的原始字节
+	conv   *encoding.Translator // 调用Use后使用的Translator，为nil时使用包的默认设置
 }
 
 func (p *Encoder) encode(x interface{}) error {
 	var e error
 	switch x.(type) {
-	case int64:
+	case int64, uint64:
 		if _, e = fmt.Fprintf(p.Writer, "i%de", x); e != nil {
 			return e
 		}
@@ -27,6 +41,17 @@ func (p *Encoder) encode(x interface{}) error {
 		if _, e = fmt.Fprintf(p.Writer, "%d:%s", len(x.(string)), x); e != nil {
 			return e
 		}
+	case []byte:
+		if _, e = fmt.Fprintf(p.Writer, "%d:%s", len(x.([]byte)), x); e != nil {
+			return e
+		}
+	case RawMessage:
+		if len(x.(RawMessage)) == 0 {
+			return EmptyRawMessage
+		}
+		if _, e = p.Writer.Write(x.(RawMessage)); e != nil {
+			return e
+		}
 	case []interface{}:
 		if _, e = p.Writer.Write([]byte{'l'}); e != nil {
 			return e
@@ -40,139 +65,296 @@ func (p *Encoder) encode(x interface{}) error {
 			return e
 		}
 	case []encoding.Item:
-		if _, e = p.Writer.Write([]byte{'d'}); e != nil {
-			return e
-		}
+		d := make([]encoding.Attr, 0, len(x.([]encoding.Item)))
 		for _, u := range x.([]encoding.Item) {
 			k, ok := u.K.(string)
 			if !ok {
 				return encoding.UnsupportType
 			}
-			if _, e = fmt.Fprintf(p.Writer, "%d:%s", len(k), k); e != nil {
-				return e
-			}
-			if e = p.encode(u.V); e != nil {
-				return e
-			}
-		}
-		if _, e = p.Writer.Write([]byte{'e'}); e != nil {
-			return e
+			d = append(d, encoding.Attr{K: k, V: u.V})
 		}
+		return p.dict(d)
 	case []encoding.Attr:
-		if _, e = p.Writer.Write([]byte{'d'}); e != nil {
-			return e
+		return p.dict(x.([]encoding.Attr))
+	case *encoding.Node:
+		return p.encode(x.(*encoding.Node).Value(""))
+	default:
+		return encoding.UnsupportType
+	}
+	return nil
+}
+
+// 编码字典，规范形式下按键的字节序排列并拒绝重复的键
+func (p *Encoder) dict(d []encoding.Attr) error {
+	if !p.legacy {
+		less := func(i, j int) bool { return d[i].K < d[j].K }
+		if !sort.SliceIsSorted(d, less) {
+			d = append([]encoding.Attr(nil), d...)
+			sort.SliceStable(d, less)
 		}
-		for _, u := range x.([]encoding.Attr) {
-			if _, e = fmt.Fprintf(p.Writer, "%d:%s", len(u.K), u.K); e != nil {
-				return e
-			}
-			if e = p.encode(u.V); e != nil {
-				return e
+		for i := 1; i < len(d); i++ {
+			if d[i].K == d[i-1].K {
+				return fmt.Errorf("%w %q", DuplicateKey, d[i].K)
 			}
 		}
-		if _, e = p.Writer.Write([]byte{'e'}); e != nil {
+	}
+	if _, e := p.Writer.Write([]byte{'d'}); e != nil {
+		return e
+	}
+	for _, u := range d {
+		if _, e := fmt.Fprintf(p.Writer, "%d:%s", len(u.K), u.K); e != nil {
+			return e
+		}
+		if e := p.encode(u.V); e != nil {
 			return e
 		}
-	default:
-		return encoding.UnsupportType
 	}
-	return nil
+	_, e := p.Writer.Write([]byte{'e'})
+	return e
+}
+
+// 生成格式错误，其位置为最后读取的字节
+func (p *Decoder) syntax(msg string) error {
+	return &encoding.FormatError{Offset: p.InputOffset() - 1, Msg: msg}
+}
+
+// 生成超出资源限制的错误
+func (p *Decoder) limit(e error) error {
+	return &encoding.LimitError{Offset: p.InputOffset(), Err: e}
+}
+
+// 读取值中间的一个字节，输入结束时返回io.ErrUnexpectedEOF
+func (p *Decoder) next() (byte, error) {
+	if e := p.limits.Bytes(p.InputOffset() - p.start + 1); e != nil {
+		return 0, p.limit(e)
+	}
+	c, e := p.ReadByte()
+	if e == io.EOF {
+		e = io.ErrUnexpectedEOF
+	}
+	if e == nil && p.rec != nil {
+		p.rec.WriteByte(c)
+	}
+	return c, e
 }
 
-func (p *Decoder) number() int {
-	n, t := 0, true
-	c := p.Iterator.ReadByte()
+// 读取十进制整数，c为已读取的第一个字节，同时返回整数之后的字节
+//
+// 超出int64范围时返回错误；规范模式下拒绝空的整数、多余的前导零和-0
+func (p *Decoder) number(c byte) (int64, byte, error) {
+	var (
+		n uint64
+		k int // 已读取的数字个数
+		t = true
+		e error
+	)
 	if c == '-' {
-		t, c = false, p.Iterator.ReadByte()
+		if c, e = p.next(); e != nil {
+			return 0, 0, e
+		}
+		t = false
+	}
+	m := uint64(math.MaxInt64)
+	if !t {
+		m++
 	}
-	for {
-		if c < '0' || c > '9' {
-			p.Iterator.UnreadByte()
-			break
+	for ; c >= '0' && c <= '9'; k++ {
+		if p.canon && k == 1 && n == 0 {
+			return 0, 0, p.syntax("leading zero in number")
+		}
+		d := uint64(c - '0')
+		if n > (m-d)/10 {
+			return 0, 0, p.syntax("integer overflows int64")
+		}
+		n = n*10 + d
+		if c, e = p.next(); e != nil {
+			return 0, 0, e
+		}
+	}
+	if p.canon {
+		if k == 0 {
+			return 0, 0, p.syntax("missing digits in number")
+		}
+		if !t && n == 0 {
+			return 0, 0, p.syntax("negative zero")
 		}
-		n = n*10 + int(c-'0')
-		c = p.Iterator.ReadByte()
 	}
 	if t {
-		return n
+		return int64(n), c, nil
 	}
-	return -n
+	return int64(-n), c, nil
 }
 
-func (p *Decoder) decode() (obj interface{}, err error) {
-	var (
-		c byte
-		n int
-		s string
-		t int
-	)
-	defer func() {
-		if e := recover(); e != nil {
-			if t == 0 {
-				obj, err = nil, e.(error)
-			} else {
-				obj, err = nil, encoding.SyntaxError
-			}
+// 读取字符串，c为已读取的长度的第一个字节
+func (p *Decoder) str(c byte) (string, error) {
+	n, c, e := p.number(c)
+	if e != nil {
+		return "", e
+	}
+	if c != ':' {
+		return "", p.syntax("string length not followed by ':'")
+	}
+	if n < 0 || int64(int(n)) != n {
+		return "", p.syntax("invalid string length")
+	}
+	if e = p.limits.String(int(n)); e == nil {
+		e = p.limits.Bytes(p.InputOffset() - p.start + n)
+	}
+	if e != nil {
+		return "", p.limit(e)
+	}
+	b, e := p.ReadN(int(n))
+	if e == io.EOF {
+		e = io.ErrUnexpectedEOF
+	}
+	if e == nil && p.rec != nil {
+		p.rec.Write(b)
+	}
+	return string(b), e
+}
+
+// 读取并解码一个值，输入在值开始前结束时返回io.EOF；h为要填充的类型，用于为其中的RawMessage保留原始字节
+func (p *Decoder) decode(h reflect.Type) (*encoding.Node, error) {
+	p.start, p.depth = p.InputOffset(), 0
+	c, e := p.ReadByte()
+	if e != nil {
+		return nil, e
+	}
+	if h != nil && !hasRaw(h) {
+		h = nil
+	}
+	return p.value(c, h)
+}
+
+// 进入一层列表或字典
+func (p *Decoder) enter() error {
+	p.depth++
+	if e := p.limits.Depth(p.depth); e != nil {
+		return p.limit(e)
+	}
+	return nil
+}
+
+// 离开一层列表或字典
+func (p *Decoder) leave() {
+	p.depth--
+}
+
+// 解码一个值，c为已读取的第一个字节，h为该值要填充的类型（未知时为nil）
+//
+// RawMessage解码为以其为Raw的节点；非规范形式下重复的键以后出现的值为准
+func (p *Decoder) value(c byte, h reflect.Type) (*encoding.Node, error) {
+	o := p.InputOffset() - 1
+	for h != nil && h.Kind() == reflect.Ptr {
+		h = h.Elem()
+	}
+	if h == rawType && p.rec == nil {
+		p.rec = bytes.NewBuffer([]byte{c})
+		_, e := p.value(c, nil)
+		b := p.rec.Bytes()
+		p.rec = nil
+		if e != nil {
+			return nil, e
+		}
+		return &encoding.Node{Kind: encoding.RawNode, Raw: RawMessage(b), Offset: o}, nil
+	}
+	switch {
+	case c == 'i':
+		c, e := p.next()
+		if e != nil {
+			return nil, e
+		}
+		n, c, e := p.number(c)
+		if e != nil {
+			return nil, e
 		}
-	}()
-	c = p.Iterator.ReadByte()
-	t = 1
-	switch c {
-	case 'i':
-		n = p.number()
-		c = p.Iterator.ReadByte()
 		if c != 'e' {
-			return nil, encoding.SyntaxError
+			return nil, p.syntax("integer not terminated by 'e'")
+		}
+		return &encoding.Node{Kind: encoding.IntNode, Int: n, Offset: o}, nil
+	case c == 'l':
+		if e := p.enter(); e != nil {
+			return nil, e
 		}
-		return int64(n), nil
-	case 'l':
-		l := []interface{}{}
+		defer p.leave()
+		l := &encoding.Node{Kind: encoding.ListNode, List: []*encoding.Node{}, Offset: o}
 		for {
-			c = p.Iterator.ReadByte()
+			c, e := p.next()
+			if e != nil {
+				return nil, e
+			}
 			if c == 'e' {
 				return l, nil
 			}
-			p.Iterator.UnreadByte()
-			i, e := p.decode()
+			if e = p.limits.Length(len(l.List) + 1); e != nil {
+				return nil, p.limit(e)
+			}
+			v, e := p.value(c, elemOf(h))
 			if e != nil {
 				return nil, e
 			}
-			l = append(l, i)
+			l.List = append(l.List, v)
 		}
-	case 'd':
-		d := map[string]interface{}{}
+	case c == 'd':
+		if e := p.enter(); e != nil {
+			return nil, e
+		}
+		defer p.leave()
+		d := &encoding.Node{Kind: encoding.DictNode, Dict: []encoding.Field{}, Offset: o}
+		seen := map[string]int{}
+		prev := ""
 		for {
-			c = p.Iterator.ReadByte()
+			c, e := p.next()
+			if e != nil {
+				return nil, e
+			}
 			if c == 'e' {
 				return d, nil
 			}
 			if c < '0' || c > '9' {
-				return nil, encoding.SyntaxError
+				return nil, p.syntax("dictionary key is not a string")
 			}
-			p.Iterator.UnreadByte()
-			n = p.number()
-			c = p.Iterator.ReadByte()
-			if c != ':' {
-				return nil, encoding.SyntaxError
+			if e = p.limits.Length(len(d.Dict) + 1); e != nil {
+				return nil, p.limit(e)
 			}
-			s = string(p.Iterator.ReadBytes(n))
-			i, e := p.decode()
+			o := p.InputOffset() - 1
+			k, e := p.str(c)
 			if e != nil {
 				return nil, e
 			}
-			d[s] = i
-		}
-	default:
-		if c < '0' || c > '9' {
-			return nil, encoding.SyntaxError
+			if p.canon && len(d.Dict) > 0 {
+				if k == prev {
+					return nil, &encoding.FormatError{Offset: o, Msg: fmt.Sprintf("duplicate dictionary key %q", k)}
+				}
+				if k < prev {
+					return nil, &encoding.FormatError{Offset: o, Msg: fmt.Sprintf("dictionary key %q out of order", k)}
+				}
+			}
+			prev = k
+			if c, e = p.next(); e != nil {
+				return nil, e
+			}
+			var y reflect.Type
+			if h != nil {
+				y = fieldOf(h, k)
+			}
+			v, e := p.value(c, y)
+			if e != nil {
+				return nil, e
+			}
+			if i, ok := seen[k]; ok {
+				d.Dict[i].Value = v
+			} else {
+				seen[k] = len(d.Dict)
+				d.Dict = append(d.Dict, encoding.Field{Key: k, Value: v})
+			}
 		}
-		p.Iterator.UnreadByte()
-		n = p.number()
-		c = p.Iterator.ReadByte()
-		if c != ':' {
-			return nil, encoding.SyntaxError
+	case c >= '0' && c <= '9':
+		s, e := p.str(c)
+		if e != nil {
+			return nil, e
 		}
-		s = string(p.Iterator.ReadBytes(n))
-		return s, nil
+		return &encoding.Node{Kind: encoding.StringNode, Str: s, Offset: o}, nil
 	}
+	return nil, p.syntax(fmt.Sprintf("invalid byte %q", c))
 }
@@ -0,0 +1,291 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/hydra13142/encoding"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sparse struct {
+	L []int          `bencode:"l,omitempty"`
+	M map[string]int `bencode:"m,omitempty"`
+}
+
+func TestKeepEmpty(t *testing.T) {
+	for _, c := range []struct {
+		x    sparse
+		keep bool
+		want string
+	}{
+		{sparse{}, false, "de"},
+		{sparse{}, true, "de"},
+		{sparse{[]int{}, map[string]int{}}, false, "de"},
+		{sparse{[]int{}, map[string]int{}}, true, "d1:lle1:mdee"},
+	} {
+		var w bytes.Buffer
+		e := NewEncoder(&w)
+		if c.keep {
+			e.KeepEmpty()
+		}
+		if err := e.Encode(c.x); err != nil || w.String() != c.want {
+			t.Errorf("Encode(%#v), keep %v = %q, %v; want %q", c.x, c.keep, w.String(), err, c.want)
+		}
+	}
+}
+
+func TestNode(t *testing.T) {
+	in := "d8:announce3:url4:infod5:filesld6:lengthi1e4:pathl1:a1:beed6:lengthi2e4:pathl1:ceee4:name3:diree"
+	n, err := NewDecoder(bytes.NewReader([]byte(in))).DecodeNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := n.Get("info.files[0].path[1]"); p == nil || p.Str != "b" {
+		t.Fatalf("Get = %+v", p)
+	}
+	if p := n.Get("info.files[1].length"); p == nil || p.Int != 2 {
+		t.Fatalf("Get = %+v", p)
+	}
+	var w bytes.Buffer
+	if err = NewEncoder(&w).EncodeNode(n); err != nil || w.String() != in {
+		t.Fatalf("EncodeNode = %q, %v", w.String(), err)
+	}
+	var x Torrent
+	if err = translator.Decode(reflect.ValueOf(&x).Elem(), n); err != nil || len(x.Info.Files) != 2 || x.Info.Files[0].Path[1] != "b" {
+		t.Fatalf("Decode(node) = %+v, %v", x, err)
+	}
+}
+
+func TestUnmatchedOffset(t *testing.T) {
+	for _, c := range []struct {
+		in, bad, path string
+	}{
+		{"d4:infod5:filesld6:lengthi1e4:pathl1:aeed6:length1:x4:pathl1:beee4:name1:nee", "1:x", "Info.Files[1].Length"},
+		{"d4:infod4:name1:n6:pieces2:ab12:piece lengthle5:filesleee", "le5:", "Info.PieceLength"},
+		{"d8:announcei1ee", "i1e", "Announce"},
+		{"le", "le", ""},
+	} {
+		var x Torrent
+		err := Unmarshal([]byte(c.in), &x)
+		var u *encoding.UnmatchedError
+		if !errors.As(err, &u) {
+			t.Errorf("%q: got %v", c.in, err)
+			continue
+		}
+		if want := int64(strings.Index(c.in, c.bad)); u.Offset != want || u.Path != c.path {
+			t.Errorf("%q: offset %d at %q, want %d at %q", c.in, u.Offset, u.Path, want, c.path)
+		}
+		if want := fmt.Sprintf("(at offset %d)", u.Offset); !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: message %q does not contain %q", c.in, err, want)
+		}
+	}
+}
+
+type unsorted struct {
+	Z int            `bencode:"z"`
+	A int            `bencode:"a"`
+	M map[string]int `bencode:"m,omitempty"`
+}
+
+func TestCanonicalEncode(t *testing.T) {
+	one := &encoding.Node{Kind: encoding.IntNode, Int: 1}
+	dup := &encoding.Node{Kind: encoding.DictNode, Dict: []encoding.Field{{Key: "b", Value: one}, {Key: "a", Value: one}, {Key: "b", Value: one}}}
+	for _, c := range []struct {
+		x      interface{}
+		legacy bool
+		want   string
+	}{
+		{unsorted{1, 2, map[string]int{"y": 1, "b": 2}}, false, "d1:ai2e1:md1:bi2e1:yi1ee1:zi1ee"},
+		{unsorted{1, 2, nil}, true, "d1:zi1e1:ai2ee"},
+		{map[string]int{"\xff": 1, "a": 2, "B": 3}, false, "d1:Bi3e1:ai2e1:\xffi1ee"},
+		{dup, true, "d1:bi1e1:ai1e1:bi1ee"},
+	} {
+		var w bytes.Buffer
+		e := NewEncoder(&w)
+		if c.legacy {
+			e.PreserveOrder()
+		}
+		var err error
+		if n, ok := c.x.(*encoding.Node); ok {
+			err = e.EncodeNode(n)
+		} else {
+			err = e.Encode(c.x)
+		}
+		if err != nil || w.String() != c.want {
+			t.Errorf("%v: got %q, %v; want %q", c.x, w.String(), err, c.want)
+		}
+	}
+	if err := NewEncoder(new(bytes.Buffer)).EncodeNode(dup); !errors.Is(err, DuplicateKey) {
+		t.Fatalf("got %v, want DuplicateKey", err)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	for _, c := range []struct {
+		in     string
+		offset int64 // 规范模式下错误的位置，-1表示接受
+		lax    bool  // 非规范模式下是否接受
+	}{
+		{"i0e", -1, true},
+		{"i-1e", -1, true},
+		{"i9223372036854775807e", -1, true},
+		{"i-9223372036854775808e", -1, true},
+		{"0:", -1, true},
+		{"d1:ai1e1:bi2ee", -1, true},
+		{"i007e", 2, true},
+		{"i-0e", 3, true},
+		{"ie", 1, true},
+		{"i-e", 2, true},
+		{"03:abc", 1, true},
+		{"d1:bi1e1:ai2ee", 7, true},
+		{"d1:ai1e1:ai2ee", 7, true},
+		{"i9223372036854775808e", 19, false},
+		{"i-9223372036854775809e", 20, false},
+	} {
+		var v interface{}
+		d := NewDecoder(strings.NewReader(c.in))
+		d.RequireCanonical()
+		err := d.Decode(&v)
+		var f *encoding.FormatError
+		switch {
+		case c.offset < 0 && err != nil:
+			t.Errorf("%s: %v", c.in, err)
+		case c.offset >= 0 && (!errors.As(err, &f) || f.Offset != c.offset):
+			t.Errorf("%s: got %v, want error at offset %d", c.in, err, c.offset)
+		}
+		if err = Unmarshal([]byte(c.in), &v); (err == nil) != c.lax {
+			t.Errorf("%s: lax decoding returned %v", c.in, err)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	lim := encoding.Limits{MaxString: 10, MaxLength: 3, MaxDepth: 3, MaxBytes: 100}
+	for _, c := range []struct {
+		in     string
+		lim    encoding.Limits
+		want   error
+		offset int64
+	}{
+		{"5:hello", lim, nil, 0},
+		{"llleee", lim, nil, 0},
+		{"11:hello world", lim, encoding.StringTooLong, 3},
+		{"li1ei2ei3ei4ee", lim, encoding.TooManyItems, 11},
+		{"d1:ai1e1:bi1e1:ci1e1:di1ee", lim, encoding.TooManyItems, 20},
+		{"llllee", lim, encoding.NestingTooDeep, 4},
+		{"l" + strings.Repeat("1:a", 40) + "e", encoding.Limits{MaxBytes: 50}, encoding.ValueTooLarge, 50},
+		// 长度头部很大时在分配之前即报错
+		{"99999999999:abc", lim, encoding.StringTooLong, 12},
+	} {
+		var v interface{}
+		d := NewDecoder(strings.NewReader(c.in))
+		d.SetLimits(c.lim)
+		err := d.Decode(&v)
+		var l *encoding.LimitError
+		if c.want == nil && err != nil || c.want != nil && (!errors.As(err, &l) || l.Err != c.want || l.Offset != c.offset) {
+			t.Errorf("%.20s: got %v, want %v at offset %d", c.in, err, c.want, c.offset)
+		}
+	}
+	// MaxBytes按每个顶层值计算
+	d := NewDecoder(strings.NewReader(strings.Repeat("5:hello", 30)))
+	d.SetLimits(encoding.Limits{MaxBytes: 8})
+	for i := 0; i < 30; i++ {
+		var v string
+		if err := d.Decode(&v); err != nil {
+			t.Fatal(i, err)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	type peer struct {
+		IP   string `bencode:"ip"`
+		Port int    `bencode:"port"`
+	}
+	p, err := UnmarshalAs[peer]([]byte("d2:ip3:::14:porti80ee"))
+	if err != nil || p != (peer{"::1", 80}) {
+		t.Fatalf("got %+v, %v", p, err)
+	}
+	b, err := Marshal(p)
+	if err != nil || string(b) != "d2:ip3:::14:porti80ee" {
+		t.Fatalf("Marshal = %q, %v", b, err)
+	}
+	var f *encoding.FormatError
+	for _, c := range []struct {
+		in     string
+		offset int64
+	}{
+		{"i1ei2e", 3},
+		{"i1e ", 3},
+	} {
+		if _, err = UnmarshalAs[int]([]byte(c.in)); !errors.As(err, &f) || f.Offset != c.offset {
+			t.Errorf("%q: got %v, want trailing data at %d", c.in, err, c.offset)
+		}
+	}
+	if _, err = UnmarshalAs[int](nil); err == nil {
+		t.Error("empty input accepted")
+	}
+	if _, err = UnmarshalAs[string]([]byte("i1e")); !errors.Is(err, encoding.UnmatchedType) {
+		t.Errorf("got %v, want UnmatchedType", err)
+	}
+}
+
+func TestStreamOffset(t *testing.T) {
+	d := NewDecoder(strings.NewReader("i1e 3:abcli2ee"))
+	var n int
+	if err := d.Decode(&n); err != nil || d.InputOffset() != 3 {
+		t.Fatalf("got %d, %v at %d", n, err, d.InputOffset())
+	}
+	if b, err := d.Peek(1); err != nil || string(b) != " " {
+		t.Fatalf("Peek = %q, %v", b, err)
+	}
+	if err := d.Skip(1); err != nil || d.InputOffset() != 4 {
+		t.Fatalf("Skip: %v at %d", err, d.InputOffset())
+	}
+	var s string
+	if err := d.Decode(&s); err != nil || s != "abc" || d.InputOffset() != 9 {
+		t.Fatalf("got %q, %v at %d", s, err, d.InputOffset())
+	}
+	var l []int
+	if err := d.Decode(&l); err != nil || d.InputOffset() != 14 || d.Buffered() != 0 {
+		t.Fatalf("got %v, %v at %d", l, err, d.InputOffset())
+	}
+	if err := d.Decode(&l); err != io.EOF {
+		t.Fatalf("got %v at end, want io.EOF", err)
+	}
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	type inner struct {
+		A int `bencode:"a"`
+	}
+	type outer struct {
+		L []inner `bencode:"l"`
+	}
+	var v outer
+	if err := Unmarshal([]byte("d1:lld1:ai1e1:bi2eeee"), &v); err != nil || v.L[0].A != 1 {
+		t.Fatalf("lax: %+v, %v", v, err)
+	}
+	d := NewDecoder(strings.NewReader("d1:lld1:ai1eed1:ai1e1:bi2eeee"))
+	d.DisallowUnknownFields()
+	err := d.Decode(&v)
+	var u *encoding.UnknownFieldError
+	if !errors.As(err, &u) || u.Key != "b" || u.Path != "L[1]" {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestOmitTypedNil(t *testing.T) {
+	b, err := Marshal(struct {
+		V interface{} `bencode:"v,omitempty"`
+	}{(*time.Time)(nil)})
+	// 接口持有的nil指针不是零值，编码为空值，bencode无法表示；不应panic
+	if !errors.Is(err, encoding.UnsupportType) {
+		t.Fatalf("got %q, %v", b, err)
+	}
+}
@@ -0,0 +1,73 @@
+package bencode
+
+import (
+	"bytes"
+	"github.com/hydra13142/encoding"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type stamped struct {
+	T time.Time     `bencode:"t"`
+	D time.Duration `bencode:"d"`
+}
+
+func TestTimeFormats(t *testing.T) {
+	x := stamped{time.Unix(1500000000, 0).UTC(), 1500 * time.Millisecond}
+	b, e := Marshal(x)
+	if e != nil || string(b) != "d1:di1500000000e1:ti1500000000ee" {
+		t.Fatalf("Marshal = %q, %v", b, e)
+	}
+	for _, s := range []string{
+		"d1:di1500000000e1:ti1500000000ee",
+		"d1:di1500000000e1:t20:2017-07-14T02:40:00Ze",
+		"d1:di1500000000e1:t25:2017-07-14T04:40:00+02:00e",
+		"d1:di1500000000e1:t30:2017-07-14T02:40:00.000000000Ze",
+	} {
+		var y stamped
+		if e = Unmarshal([]byte(s), &y); e != nil || !y.T.Equal(x.T) || y.D != x.D {
+			t.Errorf("Unmarshal(%q) = %v, %v", s, y, e)
+		}
+	}
+	var y stamped
+	if e = Unmarshal([]byte("d1:t4:nowse"), &y); e == nil {
+		t.Errorf("Unmarshal of bad time text succeeded: %v", y)
+	}
+}
+
+func TestUse(t *testing.T) {
+	x := stamped{time.Unix(1500000000, 0).UTC(), 1500 * time.Millisecond}
+	for _, c := range []struct {
+		conv []encoding.Converter
+		want string
+	}{
+		{nil, "d1:di1500000000e1:ti1500000000ee"},
+		{[]encoding.Converter{encoding.Milliseconds}, "d1:di1500e1:ti1500000000ee"},
+		// 关闭内置的time.Time转换器后按MarshalText编码
+		{[]encoding.Converter{{Type: reflect.TypeOf(time.Time{})}}, "d1:di1500000000e1:t20:2017-07-14T02:40:00Ze"},
+	} {
+		var w bytes.Buffer
+		e := NewEncoder(&w)
+		e.Use(c.conv...)
+		if err := e.Encode(x); err != nil || w.String() != c.want {
+			t.Errorf("%v: got %q, %v; want %q", c.conv, w.String(), err, c.want)
+		}
+		d := NewDecoder(&w)
+		d.Use(c.conv...)
+		var y stamped
+		if err := d.Decode(&y); err != nil || !y.T.Equal(x.T) || y.D != x.D {
+			t.Errorf("%v: decoded %v, %v", c.conv, y, err)
+		}
+	}
+	// 其他编码器不受影响
+	if b, _ := Marshal(x); string(b) != "d1:di1500000000e1:ti1500000000ee" {
+		t.Errorf("package default changed: %q", b)
+	}
+	// 不足一毫秒的部分无法用bencode表示
+	e := NewEncoder(new(bytes.Buffer))
+	e.Use(encoding.Milliseconds)
+	if err := e.Encode(stamped{D: 1500 * time.Microsecond}); err == nil {
+		t.Error("sub-millisecond duration silently truncated")
+	}
+}
@@ -0,0 +1,251 @@
+package bencode
+
+import (
+	"crypto/sha1"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// 路径下没有可加入种子的文件
+	NoFiles = errors.New("no files to create torrent")
+	// 计算hash期间文件的大小发生了变化
+	FileChanged = errors.New("file changed while hashing")
+	// 分块大小不是不小于16KiB的2的幂
+	InvalidPieceLength = errors.New("invalid piece length")
+)
+
+// CreateTorrent的选项，零值均表示默认
+type CreateOptions struct {
+	Name         string     // 种子的名称，默认为路径的最后一个元素
+	PieceLength  int        // 分块大小，须为2的幂且不小于16KiB，为0时按总大小自动选择
+	Announce     string     // tracker地址，为空时取AnnounceList的第一个
+	AnnounceList [][]string // 分层的tracker列表（BEP 12）
+	Comment      string
+	CreatedBy    string
+	CreationDate time.Time               // 创建时间，默认为当前时间
+	Private      bool                    // 私有种子（BEP 27）
+	Workers      int                     // 并行计算hash的协程数，默认为1
+	Progress     func(done, total int64) // 每完成一个分块时调用，done为已完成的字节数；总是在同一协程中调用
+}
+
+// 分块大小的范围
+const (
+	minPieceLength = 16 << 10
+	maxPieceLength = 16 << 20
+)
+
+// 按总大小选择分块大小，使分块数约为1000到2000，并限制在16KiB到16MiB之间
+func PieceLength(total int64) int {
+	n := minPieceLength
+	for n < maxPieceLength && total/int64(n) > 2000 {
+		n <<= 1
+	}
+	return n
+}
+
+// 由文件或目录创建种子：遍历其中的普通文件（按路径排序，不跟随符号链接），跨越文件边界计算各分块的SHA-1
+func CreateTorrent(path string, opts *CreateOptions) (*Torrent, error) {
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+	path, e := filepath.Abs(path)
+	if e != nil {
+		return nil, e
+	}
+	s, e := os.Stat(path)
+	if e != nil {
+		return nil, e
+	}
+	t := &Torrent{
+		Announce:     opts.Announce,
+		AnnounceList: opts.AnnounceList,
+		CreateBy:     opts.CreatedBy,
+		Comment:      opts.Comment,
+	}
+	if t.Announce == "" && len(t.AnnounceList) > 0 && len(t.AnnounceList[0]) > 0 {
+		t.Announce = t.AnnounceList[0][0]
+	}
+	if opts.CreationDate.IsZero() {
+		t.CreateDate = int(time.Now().Unix())
+	} else {
+		t.CreateDate = int(opts.CreationDate.Unix())
+	}
+	t.Info.Name = opts.Name
+	if t.Info.Name == "" {
+		t.Info.Name = filepath.Base(path)
+	}
+	if opts.Private {
+		t.Info.Private = 1
+	}
+	var (
+		list  []string
+		total int64
+	)
+	if s.Mode().IsRegular() {
+		list, total = []string{path}, s.Size()
+		t.Info.Length = int(total)
+	} else if s.IsDir() {
+		e = filepath.WalkDir(path, func(p string, d fs.DirEntry, e error) error {
+			if e != nil || !d.Type().IsRegular() {
+				return e
+			}
+			i, e := d.Info()
+			if e != nil {
+				return e
+			}
+			r, e := filepath.Rel(path, p)
+			if e != nil {
+				return e
+			}
+			list, total = append(list, p), total+i.Size()
+			t.Info.Files = append(t.Info.Files, File{Length: int(i.Size()), Path: splitPath(r)})
+			return nil
+		})
+		if e != nil {
+			return nil, e
+		}
+		if len(list) == 0 {
+			return nil, NoFiles
+		}
+	} else {
+		return nil, NoFiles
+	}
+	t.Info.PieceLength = opts.PieceLength
+	if t.Info.PieceLength == 0 {
+		t.Info.PieceLength = PieceLength(total)
+	} else if n := t.Info.PieceLength; n < minPieceLength || n&(n-1) != 0 {
+		return nil, InvalidPieceLength
+	}
+	t.Info.Pieces, e = hashPieces(list, int64(t.Info.PieceLength), total, opts.Workers, opts.Progress)
+	if e != nil {
+		return nil, e
+	}
+	return t, nil
+}
+
+// 将相对路径拆分为各级的名称
+func splitPath(p string) []string {
+	var l []string
+	for {
+		d, f := filepath.Split(p)
+		l = append([]string{f}, l...)
+		if d == "" {
+			return l
+		}
+		p = filepath.Clean(d)
+	}
+}
+
+// 依次读取多个文件的内容，如同一个文件
+type chain struct {
+	list []string
+	f    *os.File
+}
+
+func (this *chain) Read(data []byte) (int, error) {
+	for {
+		if this.f == nil {
+			if len(this.list) == 0 {
+				return 0, io.EOF
+			}
+			f, e := os.Open(this.list[0])
+			if e != nil {
+				return 0, e
+			}
+			this.f, this.list = f, this.list[1:]
+		}
+		n, e := this.f.Read(data)
+		if e == io.EOF {
+			this.f.Close()
+			this.f, e = nil, nil
+			if n == 0 {
+				continue
+			}
+		}
+		return n, e
+	}
+}
+
+func (this *chain) Close() {
+	if this.f != nil {
+		this.f.Close()
+	}
+}
+
+// 一个待计算hash的分块
+type piece struct {
+	i int
+	b []byte
+}
+
+// 计算各分块的SHA-1并依次连接；读取在一个协程中进行，hash由workers个协程并行计算
+func hashPieces(list []string, size, total int64, workers int, progress func(int64, int64)) (string, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	out := make([]byte, (total+size-1)/size*sha1.Size)
+	jobs := make(chan piece, workers)
+	done := make(chan int, workers)
+	free := make(chan []byte, workers*2)
+	for i := 0; i < cap(free); i++ {
+		free <- make([]byte, size)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				h := sha1.Sum(p.b)
+				copy(out[p.i*sha1.Size:], h[:])
+				done <- len(p.b)
+				free <- p.b[:cap(p.b)]
+			}
+		}()
+	}
+	var e error
+	go func() {
+		defer close(jobs)
+		r := &chain{list: list}
+		defer r.Close()
+		for i, k := 0, total; k > 0; i++ {
+			n := size
+			if k < n {
+				n = k
+			}
+			b := <-free
+			if _, e = io.ReadFull(r, b[:n]); e != nil {
+				if e == io.EOF || e == io.ErrUnexpectedEOF {
+					e = FileChanged
+				}
+				return
+			}
+			jobs <- piece{i, b[:n]}
+			k -= n
+		}
+		if n, _ := r.Read(make([]byte, 1)); n != 0 {
+			e = FileChanged
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	var c int64
+	for n := range done {
+		c += int64(n)
+		if progress != nil {
+			progress(c, total)
+		}
+	}
+	if e != nil {
+		return "", e
+	}
+	return string(out), nil
+}
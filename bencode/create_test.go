@@ -0,0 +1,152 @@
+package bencode
+
+import (
+	"crypto/sha1"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 在dir下创建文件，返回按路径顺序连接的全部内容
+func makeFiles(t *testing.T, dir string, files []struct {
+	p string
+	n int
+}) []byte {
+	var all []byte
+	for k, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(f.p))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, f.n)
+		for i := range b {
+			b[i] = byte(i*31 + k)
+		}
+		if err := os.WriteFile(p, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, b...)
+	}
+	return all
+}
+
+// 按分块大小n计算连接后的各分块的SHA-1
+func pieceHashes(all []byte, n int) string {
+	var s []byte
+	for i := 0; i < len(all); i += n {
+		j := i + n
+		if j > len(all) {
+			j = len(all)
+		}
+		h := sha1.Sum(all[i:j])
+		s = append(s, h[:]...)
+	}
+	return string(s)
+}
+
+func TestCreateTorrent(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "set")
+	// 分块跨越文件边界，空文件不影响分块
+	all := makeFiles(t, root, []struct {
+		p string
+		n int
+	}{{"a.bin", 40000}, {"empty", 0}, {"sub/b.bin", 70001}, {"sub/deep/c", 5}, {"z", 16384}})
+	if err := os.Symlink(filepath.Join(root, "z"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	want := pieceHashes(all, 16384)
+	files := []File{
+		{Length: 40000, Path: []string{"a.bin"}},
+		{Length: 0, Path: []string{"empty"}},
+		{Length: 70001, Path: []string{"sub", "b.bin"}},
+		{Length: 5, Path: []string{"sub", "deep", "c"}},
+		{Length: 16384, Path: []string{"z"}},
+	}
+	for _, w := range []int{0, 1, 4} {
+		var calls int
+		var last int64
+		tor, err := CreateTorrent(root, &CreateOptions{
+			AnnounceList: [][]string{{"http://a/announce"}, {"http://b"}},
+			Comment:      "c",
+			CreatedBy:    "x",
+			CreationDate: time.Unix(1000, 0),
+			Private:      true,
+			Workers:      w,
+			Progress: func(done, total int64) {
+				if done <= last || total != int64(len(all)) {
+					t.Errorf("workers %d: progress %d/%d after %d", w, done, total, last)
+				}
+				calls, last = calls+1, done
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case tor.Info.Pieces != want:
+			t.Errorf("workers %d: wrong pieces", w)
+		case !reflect.DeepEqual(tor.Info.Files, files):
+			t.Errorf("workers %d: files %v", w, tor.Info.Files)
+		case tor.Announce != "http://a/announce" || tor.CreateDate != 1000 || tor.Info.Private != 1 || tor.Info.Name != "set":
+			t.Errorf("workers %d: %+v", w, tor)
+		case tor.Info.PieceLength != 16384 || tor.Info.Length != 0:
+			t.Errorf("workers %d: piece length %d, length %d", w, tor.Info.PieceLength, tor.Info.Length)
+		case calls != len(want)/sha1.Size || last != int64(len(all)):
+			t.Errorf("workers %d: %d progress calls, last %d", w, calls, last)
+		}
+	}
+	tor, err := CreateTorrent(filepath.Join(root, "a.bin"), &CreateOptions{Name: "n", PieceLength: 32768})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tor.Info.Files != nil || tor.Info.Length != 40000 || tor.Info.Name != "n" || tor.Info.Pieces != pieceHashes(all[:40000], 32768) {
+		t.Errorf("single file: %+v", tor.Info)
+	}
+}
+
+func TestCreateTorrentErrors(t *testing.T) {
+	dir := t.TempDir()
+	makeFiles(t, dir, []struct {
+		p string
+		n int
+	}{{"f", 10}})
+	if err := os.Mkdir(filepath.Join(dir, "none"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		path string
+		opts *CreateOptions
+		want error
+	}{
+		{filepath.Join(dir, "none"), nil, NoFiles},
+		{dir, &CreateOptions{PieceLength: 1000}, InvalidPieceLength},
+		{dir, &CreateOptions{PieceLength: 3 << 14}, InvalidPieceLength},
+	} {
+		if _, err := CreateTorrent(c.path, c.opts); !errors.Is(err, c.want) {
+			t.Errorf("%s %+v: got %v, want %v", c.path, c.opts, err, c.want)
+		}
+	}
+	if _, err := CreateTorrent(filepath.Join(dir, "missing"), nil); !os.IsNotExist(err) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestPieceLength(t *testing.T) {
+	for _, c := range []struct {
+		total int64
+		want  int
+	}{
+		{0, 16 << 10},
+		{2000 * 16 << 10, 16 << 10},
+		{2001 * 16 << 10, 32 << 10},
+		{4 << 30, 4 << 20},
+		{1 << 50, 16 << 20},
+	} {
+		if got := PieceLength(c.total); got != c.want {
+			t.Errorf("PieceLength(%d) = %d, want %d", c.total, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package bencode
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"io"
+)
+
+// BEP 3的info hash，即info字典的SHA-1
+type HashV1 [20]byte
+
+// BEP 52的info hash，即info字典的SHA-256
+type HashV2 [32]byte
+
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// 十六进制形式（小写）
+func (this HashV1) Hex() string {
+	return hex.EncodeToString(this[:])
+}
+
+// base32形式（无填充），用于magnet链接
+func (this HashV1) Base32() string {
+	return base32NoPad.EncodeToString(this[:])
+}
+
+func (this HashV1) String() string {
+	return this.Hex()
+}
+
+// 十六进制形式（小写）
+func (this HashV2) Hex() string {
+	return hex.EncodeToString(this[:])
+}
+
+// base32形式（无填充）
+func (this HashV2) Base32() string {
+	return base32NoPad.EncodeToString(this[:])
+}
+
+func (this HashV2) String() string {
+	return this.Hex()
+}
+
+// 将Info按规范形式编码后计算v1的info hash；Info中未建模的键由其Extra保留
+//
+// 如原文件的info字典不是规范形式，结果可能与原文件不同，此时应使用InfoHash
+func (this *Torrent) InfoHashV1() (HashV1, error) {
+	b, e := Marshal(&this.Info)
+	if e != nil {
+		return HashV1{}, e
+	}
+	return sha1.Sum(b), nil
+}
+
+// 将Info按规范形式编码后计算v2的info hash，注意事项同InfoHashV1
+func (this *Torrent) InfoHashV2() (HashV2, error) {
+	b, e := Marshal(&this.Info)
+	if e != nil {
+		return HashV2{}, e
+	}
+	return sha256.Sum256(b), nil
+}
+
+// 种子文件中计算info hash所需的部分
+type metainfo struct {
+	Info RawMessage `bencode:"info,required"`
+}
+
+// 从种子文件中读取一个值，对其info字典的原始字节计算v1和v2的info hash，不经过重新编码
+//
+// 缺少info字典时返回encoding.MissingFieldError
+func InfoHash(r io.Reader) (HashV1, HashV2, error) {
+	var t metainfo
+	if e := NewDecoder(r).Decode(&t); e != nil {
+		return HashV1{}, HashV2{}, e
+	}
+	return sha1.Sum(t.Info), sha256.Sum256(t.Info), nil
+}
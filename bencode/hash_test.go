@@ -0,0 +1,96 @@
+package bencode
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+func TestInfoHash(t *testing.T) {
+	pieces := strings.Repeat("\x01", 20) + strings.Repeat("\xfe", 20)
+	root := strings.Repeat("\xaa", 32)
+	tree := map[string]interface{}{
+		"a.bin": map[string]interface{}{"": map[string]interface{}{"length": 40000, "pieces root": root}},
+		"b.bin": map[string]interface{}{"": map[string]interface{}{"length": 1000}},
+	}
+	for _, c := range []struct {
+		name string
+		info map[string]interface{}
+	}{
+		{"v1", map[string]interface{}{
+			"name": "x", "piece length": 32768, "pieces": pieces,
+			"files": []interface{}{
+				map[string]interface{}{"length": 40000, "path": []interface{}{"a.bin"}},
+				map[string]interface{}{"length": 1000, "path": []interface{}{"b.bin"}},
+			},
+		}},
+		{"v2", map[string]interface{}{
+			"name": "x", "piece length": 32768, "meta version": 2, "file tree": tree,
+		}},
+		{"hybrid", map[string]interface{}{
+			"name": "x", "piece length": 32768, "meta version": 2, "file tree": tree, "pieces": strings.Repeat("\x02", 60),
+			"files": []interface{}{
+				map[string]interface{}{"length": 40000, "path": []interface{}{"a.bin"}},
+				map[string]interface{}{"attr": "p", "length": 25536, "path": []interface{}{".pad", "25536"}},
+				map[string]interface{}{"length": 1000, "path": []interface{}{"b.bin"}},
+			},
+		}},
+	} {
+		info, err := Marshal(c.info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc := "d8:announce3:url4:info" + string(info) + "12:piece layersd32:" + root + "40:" + pieces + "ee"
+		v1, v2, err := InfoHash(strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if v1 != sha1.Sum(info) || v2 != sha256.Sum256(info) {
+			t.Fatalf("%s: InfoHash does not hash the info dictionary", c.name)
+		}
+		var tor Torrent
+		if err = Unmarshal([]byte(doc), &tor); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if b, _ := Marshal(&tor.Info); !bytes.Equal(b, info) {
+			t.Fatalf("%s: info re-encoded as\n%q\nwant\n%q", c.name, b, info)
+		}
+		if h, err := tor.InfoHashV1(); err != nil || h != v1 {
+			t.Errorf("%s: InfoHashV1 = %v, %v; want %v", c.name, h, err, v1)
+		}
+		if h, err := tor.InfoHashV2(); err != nil || h != v2 {
+			t.Errorf("%s: InfoHashV2 = %v, %v; want %v", c.name, h, err, v2)
+		}
+	}
+}
+
+func TestInfoHashRaw(t *testing.T) {
+	// 键顺序不规范的info字典按原始字节计算，重新编码后的结果不同
+	info := "d4:name1:x6:pieces0:12:piece lengthi16384ee"
+	v1, v2, err := InfoHash(strings.NewReader("d4:info" + info + "e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1 != sha1.Sum([]byte(info)) || v2 != sha256.Sum256([]byte(info)) {
+		t.Fatal("InfoHash re-encoded the info dictionary")
+	}
+	if _, _, err = InfoHash(strings.NewReader("d8:announce3:urle")); err == nil {
+		t.Fatal("missing info accepted")
+	}
+}
+
+func TestHashFormat(t *testing.T) {
+	var h HashV1
+	h[0], h[19] = 0xab, 0x01
+	if s := h.Hex(); s != "ab"+strings.Repeat("00", 18)+"01" || h.String() != s {
+		t.Errorf("Hex = %s", s)
+	}
+	if s := h.Base32(); len(s) != 32 || strings.Contains(s, "=") {
+		t.Errorf("Base32 = %s", s)
+	}
+	if s := (HashV2{}).Base32(); len(s) != 52 || strings.Contains(s, "=") {
+		t.Errorf("Base32 = %s", s)
+	}
+}
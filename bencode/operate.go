@@ -1,41 +1,133 @@
 package bencode
 
 import (
+	"bytes"
 	"errors"
 	"github.com/hydra13142/encoding"
 	"io"
 	"reflect"
 )
 
-var translator = encoding.Translator{"bencode", make(map[reflect.Type][]encoding.Label), nil}
+var rawtype = map[reflect.Type]struct{}{
+	rawType: struct{}{},
+}
+
+var translator = newTranslator()
+
+func newTranslator() *encoding.Translator {
+	t := encoding.NewTranslator("bencode", rawtype)
+	t.Sorted = true
+	t.Use(encoding.UnixTime, encoding.BigInteger, encoding.IPAddress, encoding.URLString)
+	return t
+}
+
+// 在t上注册转换器，t为nil时先创建带有默认转换器的Translator
+func use(t **encoding.Translator, c []encoding.Converter) {
+	if *t == nil {
+		*t = newTranslator()
+	}
+	(*t).Use(c...)
+}
+
+// 返回要使用的Translator的副本，t为nil时使用包的默认设置
+func pick(t *encoding.Translator) encoding.Translator {
+	if t == nil {
+		return *translator
+	}
+	return *t
+}
 
 // 解码的目标参数必须是指针
 var TypeError = errors.New("need point type")
 
+// 规范形式的字典中出现了重复的键
+var DuplicateKey = errors.New("duplicate dictionary key")
+
 // 创建编码器
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w}
+	return &Encoder{Writer: w}
 }
 
 // 创建解码器
 func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{encoding.NewIterator(r)}
+	return &Decoder{Iterator: encoding.NewIterator(r)}
+}
+
+// 设置编码器不再输出规范形式：字典按中间数据的顺序写入（结构体按字段声明的顺序），不检查重复的键，用于重现旧的编码结果
+func (this *Encoder) PreserveOrder() {
+	this.legacy = true
+}
+
+// 设置编码器在omitempty字段为非nil的空slice或map时仍然编码，只忽略nil
+func (this *Encoder) KeepEmpty() {
+	this.keep = true
+}
+
+// 为该编码器注册转换器（如encoding.Milliseconds），只影响该编码器；
+// Encode和Decode均为nil的转换器可关闭该类型的内置转换器
+func (this *Encoder) Use(c ...encoding.Converter) {
+	use(&this.conv, c)
+}
+
+// 为该解码器注册转换器，同Encoder.Use
+func (this *Decoder) Use(c ...encoding.Converter) {
+	use(&this.conv, c)
+}
+
+// 设置解码器在字典的键没有对应的结构体字段时返回错误
+func (this *Decoder) DisallowUnknownFields() {
+	this.strict = true
+}
+
+// 设置解码器拒绝非规范形式的输入：整数和字符串长度的多余前导零、-0、空的整数，以及未按字节序排列或重复的字典键，错误中给出出错位置
+func (this *Decoder) RequireCanonical() {
+	this.canon = true
+}
+
+// 设置解码不可信输入时的资源限制，超出时返回encoding.LimitError
+func (this *Decoder) SetLimits(l encoding.Limits) {
+	this.limits = l
 }
 
 // 编码对象后写入下层
 func (this *Encoder) Encode(x interface{}) error {
-	c, e := translator.Encode(reflect.ValueOf(x))
+	t := pick(this.conv)
+	t.Sorted = !this.legacy
+	t.OnlyNil = this.keep
+	c, e := t.Encode(reflect.ValueOf(x))
 	if e != nil {
 		return e
 	}
 	return this.encode(c)
 }
 
+// 编码节点后写入下层，bencode不能表示的节点返回encoding.LossError
+func (this *Encoder) EncodeNode(n *encoding.Node) error {
+	e := n.Check("bencode", func(x *encoding.Node) bool {
+		switch x.Kind {
+		case encoding.IntNode, encoding.UintNode, encoding.StringNode, encoding.BytesNode, encoding.ListNode, encoding.DictNode:
+			return true
+		case encoding.ObjectNode:
+			return x.Str == ""
+		}
+		return false
+	})
+	if e != nil {
+		return e
+	}
+	return this.encode(n)
+}
+
+// 读取一个值并解码为节点
+func (this *Decoder) DecodeNode() (*encoding.Node, error) {
+	return this.decode(nil)
+}
+
 // 读取并解码后填充对象
 func (this *Decoder) Decode(x interface{}) error {
 	v := reflect.ValueOf(x)
 	if v.Kind() == reflect.Invalid {
-		_, e := this.decode()
+		_, e := this.decode(nil)
 		if e != nil {
 			return e
 		}
@@ -43,9 +135,39 @@ func (this *Decoder) Decode(x interface{}) error {
 	} else if v.Kind() != reflect.Ptr {
 		return TypeError
 	}
-	c, e := this.decode()
+	c, e := this.decode(v.Elem().Type())
 	if e != nil {
 		return e
 	}
-	return translator.Decode(v.Elem(), c)
+	t := pick(this.conv)
+	t.Strict = this.strict
+	return t.Decode(v.Elem(), c)
+}
+
+// 编码对象并返回编码后的数据
+func Marshal(x interface{}) ([]byte, error) {
+	w := bytes.NewBuffer(nil)
+	if e := NewEncoder(w).Encode(x); e != nil {
+		return nil, e
+	}
+	return w.Bytes(), nil
+}
+
+// 解码数据并填充对象，数据须恰好包含一个值
+func Unmarshal(data []byte, x interface{}) error {
+	d := NewDecoder(bytes.NewReader(data))
+	if e := d.Decode(x); e != nil {
+		return e
+	}
+	if n, _ := d.Read(make([]byte, 1)); n != 0 {
+		return &encoding.FormatError{Offset: d.InputOffset() - 1, Msg: "trailing data after value"}
+	}
+	return nil
+}
+
+// 解码数据并返回T类型的值
+func UnmarshalAs[T any](data []byte) (T, error) {
+	var x T
+	e := Unmarshal(data, &x)
+	return x, e
 }
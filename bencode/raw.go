@@ -0,0 +1,90 @@
+package bencode
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// 未解码的bencode值的原始字节，解码时保留输入中的原样字节，编码时原样写出
+type RawMessage []byte
+
+// 编码空的RawMessage
+var EmptyRawMessage = errors.New("empty RawMessage")
+
+var (
+	rawType  = reflect.TypeOf(RawMessage(nil))
+	rawCache sync.Map // reflect.Type => bool，该类型中是否含有RawMessage
+)
+
+// 判断解码到某类型时是否可能遇到RawMessage，无则解码时不必追踪成员类型
+func hasRaw(t reflect.Type) bool {
+	if b, ok := rawCache.Load(t); ok {
+		return b.(bool)
+	}
+	b := findRaw(t, map[reflect.Type]bool{})
+	rawCache.Store(t, b)
+	return b
+}
+
+func findRaw(t reflect.Type, done map[reflect.Type]bool) bool {
+	if t == rawType {
+		return true
+	}
+	if done[t] {
+		return false
+	}
+	done[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return findRaw(t.Elem(), done)
+	case reflect.Struct:
+		for _, l := range translator.GetLabel(t) {
+			if findRaw(t.FieldByIndex(l.I).Type, done) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 列表成员的目标类型，未知时返回nil
+func elemOf(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t == rawType {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return t.Elem()
+	}
+	return nil
+}
+
+// 字典中键k的值的目标类型，未知时返回nil
+func fieldOf(t reflect.Type, k string) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		var rest reflect.Type
+		for _, l := range translator.GetLabel(t) {
+			f := t.FieldByIndex(l.I)
+			if l.Has("remain") && f.Type.Kind() == reflect.Map {
+				rest = f.Type.Elem()
+			} else if l.Name() == k {
+				return f.Type
+			}
+		}
+		return rest
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type withRaw struct {
+	Announce string                `bencode:"announce"`
+	Info     RawMessage            `bencode:"info"`
+	List     []RawMessage          `bencode:"list"`
+	M        map[string]RawMessage `bencode:"m"`
+	P        *RawMessage           `bencode:"p,omitempty"`
+}
+
+func TestRawMessage(t *testing.T) {
+	// 非规范的成员（乱序的键、前导零）也原样保留
+	in := []byte("d8:announce3:url4:infod1:zi1e1:a3:xyze4:listli03e1:xle1:yi-5ee1:md1:b0:e1:pi7ee")
+	var v withRaw
+	if err := Unmarshal(in, &v); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ got, want string }{
+		{string(v.Info), "d1:zi1e1:a3:xyze"},
+		{string(v.List[0]), "i03e"},
+		{string(v.List[2]), "le"},
+		{string(v.List[4]), "i-5e"},
+		{string(v.M["b"]), "0:"},
+		{string(*v.P), "i7e"},
+	} {
+		if c.got != c.want {
+			t.Errorf("got %q, want %q", c.got, c.want)
+		}
+	}
+	out, err := Marshal(v)
+	if err != nil || !bytes.Equal(out, in) {
+		t.Fatalf("got %s, %v", out, err)
+	}
+	var r RawMessage
+	if err = Unmarshal(in, &r); err != nil || !bytes.Equal(r, in) {
+		t.Fatalf("top level: %q, %v", r, err)
+	}
+	if _, err = Marshal(RawMessage{}); !errors.Is(err, EmptyRawMessage) {
+		t.Fatalf("got %v, want EmptyRawMessage", err)
+	}
+}
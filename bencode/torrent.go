@@ -2,29 +2,32 @@ package bencode
 
 // 代表一个torrent文件
 type Torrent struct {
-	Announce     string      `bencode:"announce"`
-	AnnounceList [][]string  `bencode:"announce-list,omitempty"`
-	CreateBy     string      `bencode:"created by,omitempty"`
-	CreateDate   int         `bencode:"creation date,omitempty"`
-	Comment      string      `bencode:"comment,omitempty"`
-	Encoding     string      `bencode:"encoding,omitempty"`
-	Info         FileInfo    `bencode:"info"`
-	Nodes        interface{} `bencode:"nodes,omitempty"`
+	Announce     string                 `bencode:"announce"`
+	AnnounceList [][]string             `bencode:"announce-list,omitempty"`
+	CreateBy     string                 `bencode:"created by,omitempty"`
+	CreateDate   int                    `bencode:"creation date,omitempty"`
+	Comment      string                 `bencode:"comment,omitempty"`
+	Encoding     string                 `bencode:"encoding,omitempty"`
+	Info         FileInfo               `bencode:"info"`
+	Nodes        interface{}            `bencode:"nodes,omitempty"`
+	Extra        map[string]interface{} `bencode:",remain"`
 }
 
 // bt种子的文件信息
 type FileInfo struct {
-	Files        []File     `bencode:"files,omitempty"`
-	Name         string     `bencode:"name"`
-	Length       int        `bencode:"length,omitempty"`
-	Ed2k         string     `bencode:"ed2k,omitempty"`
-	Md5Sum       string     `bencode:"md5sum,omitempty"`
-	FileHash     string     `bencode:"filehash,omitempty"`
-	PieceLength  int        `bencode:"piece length"`
-	Pieces       string     `bencode:"pieces"`
-	FileDuration []int      `bencode:"file-duration,omitempty"`
-	FileMedia    []int      `bencode:"file-media,omitempty"`
-	Profiles     []MetaData `bencode:"profiles,omitempty"`
+	Files        []File                 `bencode:"files,omitempty"`
+	Name         string                 `bencode:"name"`
+	Length       int                    `bencode:"length,omitempty"`
+	Ed2k         string                 `bencode:"ed2k,omitempty"`
+	Md5Sum       string                 `bencode:"md5sum,omitempty"`
+	FileHash     string                 `bencode:"filehash,omitempty"`
+	PieceLength  int                    `bencode:"piece length"`
+	Pieces       string                 `bencode:"pieces,omitempty"` // 纯v2种子没有pieces
+	FileDuration []int                  `bencode:"file-duration,omitempty"`
+	FileMedia    []int                  `bencode:"file-media,omitempty"`
+	Profiles     []MetaData             `bencode:"profiles,omitempty"`
+	Private      int                    `bencode:"private,omitempty"`
+	Extra        map[string]interface{} `bencode:",remain"`
 }
 
 // 媒体文件元数据
@@ -35,9 +38,10 @@ type MetaData struct {
 	Width  int    `bencode:"width"`
 }
 
-// 具体单个文件的路径和大小
+// 具体单个文件的路径和大小，其他键（如填充文件的attr）由Extra保留
 type File struct {
-	Length int      `bencode:"length"`
-	Md5Sum string   `bencode:"md5sum,omitempty"`
-	Path   []string `bencode:"path"`
+	Length int                    `bencode:"length"`
+	Md5Sum string                 `bencode:"md5sum,omitempty"`
+	Path   []string               `bencode:"path"`
+	Extra  map[string]interface{} `bencode:",remain"`
 }
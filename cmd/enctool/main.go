@@ -0,0 +1,258 @@
+// enctool在bencode、AMF0、AMF3和JSON之间转换标准输入中的一个值，结果写到标准输出
+//
+// 用法：
+//
+//	enctool -from bencode -to json < a.torrent
+//
+// 目标格式无法表示的值（如AMF的undefined转为bencode）会报错并退出。
+// JSON中对象的类名使用键"$"表示；字节串和不是UTF-8的字符串表示为{"$bytes":"base64编码"}，读取时还原为字节串。
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/hydra13142/encoding"
+	"github.com/hydra13142/encoding/AMF"
+	"github.com/hydra13142/encoding/bencode"
+)
+
+const (
+	class  = "$"      // JSON中表示类名的键
+	binKey = "$bytes" // JSON中表示字节串的对象唯一的键
+)
+
+func main() {
+	from := flag.String("from", "bencode", "input format: bencode, amf0, amf3 or json")
+	to := flag.String("to", "json", "output format: bencode, amf0, amf3 or json")
+	flag.Parse()
+	src, e := decoder(*from, bufio.NewReader(os.Stdin))
+	if e != nil {
+		fail(e)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	dst, e := encoder(*to, w)
+	if e != nil {
+		fail(e)
+	}
+	if e = encoding.Transcode(dst, src); e != nil {
+		fail(e)
+	}
+	if e = w.Flush(); e != nil {
+		fail(e)
+	}
+}
+
+func fail(e error) {
+	fmt.Fprintln(os.Stderr, "enctool:", e)
+	os.Exit(1)
+}
+
+func decoder(s string, r io.Reader) (encoding.Decoder, error) {
+	switch s {
+	case "bencode":
+		return bencode.NewDecoder(r), nil
+	case "amf0", "amf3":
+		d := AMF.NewDecoder(r)
+		d.AMF3 = s == "amf3"
+		return d, nil
+	case "json":
+		d := json.NewDecoder(r)
+		d.UseNumber()
+		return &jsonDecoder{d}, nil
+	}
+	return nil, fmt.Errorf("unknown input format %q", s)
+}
+
+func encoder(s string, w io.Writer) (encoding.Encoder, error) {
+	switch s {
+	case "bencode":
+		return bencode.NewEncoder(w), nil
+	case "amf0", "amf3":
+		e := AMF.NewEncoder(w)
+		e.AMF3 = s == "amf3"
+		return e, nil
+	case "json":
+		return &jsonEncoder{w}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", s)
+}
+
+// JSON解码器，保持对象中键的顺序
+type jsonDecoder struct {
+	*json.Decoder
+}
+
+func (this *jsonDecoder) DecodeNode() (*encoding.Node, error) {
+	return this.value()
+}
+
+// 读取一个值为节点，含有类名键的对象成为ObjectNode
+func (this *jsonDecoder) value() (*encoding.Node, error) {
+	t, e := this.Token()
+	if e != nil {
+		return nil, e
+	}
+	switch u := t.(type) {
+	case json.Delim:
+		if u == '[' {
+			l := &encoding.Node{Kind: encoding.ListNode, List: []*encoding.Node{}}
+			for this.More() {
+				v, e := this.value()
+				if e != nil {
+					return nil, e
+				}
+				l.List = append(l.List, v)
+			}
+			_, e = this.Token()
+			return l, e
+		}
+		d := &encoding.Node{Kind: encoding.DictNode, Dict: []encoding.Field{}}
+		for this.More() {
+			k, e := this.Token()
+			if e != nil {
+				return nil, e
+			}
+			v, e := this.value()
+			if e != nil {
+				return nil, e
+			}
+			if k == class && v.Kind == encoding.StringNode {
+				d.Kind, d.Str = encoding.ObjectNode, v.Str
+				continue
+			}
+			d.Dict = append(d.Dict, encoding.Field{Key: k.(string), Value: v})
+		}
+		if _, e = this.Token(); e != nil {
+			return nil, e
+		}
+		if f := d.Dict; d.Kind == encoding.DictNode && len(f) == 1 && f[0].Key == binKey && f[0].Value.Kind == encoding.StringNode {
+			b, e := base64.StdEncoding.DecodeString(f[0].Value.Str)
+			if e != nil {
+				return nil, e
+			}
+			return &encoding.Node{Kind: encoding.BytesNode, Bytes: b}, nil
+		}
+		return d, nil
+	case json.Number:
+		if i, e := strconv.ParseInt(string(u), 10, 64); e == nil {
+			return &encoding.Node{Kind: encoding.IntNode, Int: i}, nil
+		}
+		if i, e := strconv.ParseUint(string(u), 10, 64); e == nil {
+			return &encoding.Node{Kind: encoding.UintNode, Uint: i}, nil
+		}
+		f, e := strconv.ParseFloat(string(u), 64)
+		return &encoding.Node{Kind: encoding.FloatNode, Float: f}, e
+	case string:
+		return &encoding.Node{Kind: encoding.StringNode, Str: u}, nil
+	case bool:
+		return &encoding.Node{Kind: encoding.BoolNode, Bool: u}, nil
+	}
+	return &encoding.Node{Kind: encoding.NullNode}, nil
+}
+
+// JSON编码器
+type jsonEncoder struct {
+	io.Writer
+}
+
+func (this *jsonEncoder) EncodeNode(n *encoding.Node) error {
+	e := n.Check("json", func(x *encoding.Node) bool {
+		switch x.Kind {
+		case encoding.UndefinedNode, encoding.RawNode:
+			return false
+		case encoding.FloatNode:
+			return !math.IsNaN(x.Float) && !math.IsInf(x.Float, 0)
+		case encoding.DictNode, encoding.ObjectNode:
+			for _, f := range x.Dict {
+				if !utf8.ValidString(f.Key) {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	if e != nil {
+		return e
+	}
+	b, e := json.Marshal(jsonNode{n})
+	if e != nil {
+		return e
+	}
+	_, e = this.Write(append(b, '\n'))
+	return e
+}
+
+// 按节点的种类编码为JSON，字典保持键的顺序
+type jsonNode struct {
+	*encoding.Node
+}
+
+func (this jsonNode) MarshalJSON() ([]byte, error) {
+	n := this.Node
+	if n == nil {
+		return []byte("null"), nil
+	}
+	switch n.Kind {
+	case encoding.NullNode:
+		return []byte("null"), nil
+	case encoding.BoolNode:
+		return json.Marshal(n.Bool)
+	case encoding.IntNode:
+		return json.Marshal(n.Int)
+	case encoding.UintNode:
+		return json.Marshal(n.Uint)
+	case encoding.FloatNode:
+		return json.Marshal(n.Float)
+	case encoding.StringNode:
+		if !utf8.ValidString(n.Str) {
+			return binary([]byte(n.Str))
+		}
+		return json.Marshal(n.Str)
+	case encoding.BytesNode:
+		return binary(n.Bytes)
+	case encoding.ListNode:
+		l := make([]jsonNode, 0, len(n.List))
+		for _, v := range n.List {
+			l = append(l, jsonNode{v})
+		}
+		return json.Marshal(l)
+	case encoding.DictNode, encoding.ObjectNode:
+		b := []byte{'{'}
+		if n.Kind == encoding.ObjectNode {
+			k, _ := json.Marshal(class)
+			v, _ := json.Marshal(n.Str)
+			b = append(append(append(b, k...), ':'), v...)
+			if len(n.Dict) > 0 {
+				b = append(b, ',')
+			}
+		}
+		for i, f := range n.Dict {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			k, _ := json.Marshal(f.Key)
+			v, e := jsonNode{f.Value}.MarshalJSON()
+			if e != nil {
+				return nil, e
+			}
+			b = append(append(append(b, k...), ':'), v...)
+		}
+		return append(b, '}'), nil
+	}
+	return nil, errors.New("unexpected node kind " + n.Kind.String())
+}
+
+// 将字节串编码为{"$bytes":"base64编码"}
+func binary(b []byte) ([]byte, error) {
+	return json.Marshal(map[string]string{binKey: base64.StdEncoding.EncodeToString(b)})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hydra13142/encoding"
+	"github.com/hydra13142/encoding/bencode"
+)
+
+// 将b由from格式转换为to格式
+func transcode(t *testing.T, b []byte, from, to string) []byte {
+	t.Helper()
+	src, err := decoder(from, bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bytes.NewBuffer(nil)
+	dst, err := encoder(to, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = encoding.Transcode(dst, src); err != nil {
+		t.Fatalf("%s -> %s: %v", from, to, err)
+	}
+	return w.Bytes()
+}
+
+func TestTorrentRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for name, size := range map[string]int{"a.bin": 40000, "b/c.bin": 1000} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i * 7)
+		}
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tor, err := bencode.CreateTorrent(dir, &bencode.CreateOptions{Announce: "http://tracker/announce", CreationDate: time.Unix(1e9, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := bencode.Marshal(tor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pieces不是UTF-8，经由JSON时须保持原样
+	j := transcode(t, b, "bencode", "json")
+	if !bytes.Contains(j, []byte(`"pieces":{"$bytes":`)) {
+		t.Fatalf("pieces not wrapped: %s", j)
+	}
+	if got := transcode(t, j, "json", "bencode"); !bytes.Equal(got, b) {
+		t.Fatalf("bencode -> json -> bencode changed the torrent:\n%q\n%q", got, b)
+	}
+	for _, f := range []string{"amf0", "amf3"} {
+		a := transcode(t, b, "bencode", f)
+		if got := transcode(t, transcode(t, a, f, "json"), "json", "bencode"); !bytes.Equal(got, b) {
+			t.Fatalf("bencode -> %s -> json -> bencode changed the torrent:\n%q\n%q", f, got, b)
+		}
+	}
+}
+
+func TestJSONBytes(t *testing.T) {
+	for _, c := range []struct{ in, out string }{
+		{`"abc"`, `"abc"` + "\n"},
+		{`{"$bytes":"/w=="}`, `{"$bytes":"/w=="}` + "\n"},
+		{`{"$bytes":"/w==","x":1}`, `{"$bytes":"/w==","x":1}` + "\n"},
+		{`{"$bytes":1}`, `{"$bytes":1}` + "\n"},
+	} {
+		if got := string(transcode(t, []byte(c.in), "json", "json")); got != c.out {
+			t.Errorf("%s: got %s, want %s", c.in, got, c.out)
+		}
+	}
+	src, _ := decoder("json", bytes.NewReader([]byte(`{"$bytes":"!"}`)))
+	if _, err := src.DecodeNode(); err == nil {
+		t.Error("invalid base64 accepted")
+	}
+	dst, _ := encoder("json", bytes.NewBuffer(nil))
+	n := &encoding.Node{Kind: encoding.DictNode, Dict: []encoding.Field{{Key: "\xff", Value: &encoding.Node{Kind: encoding.NullNode}}}}
+	if err := dst.EncodeNode(n); err == nil {
+		t.Error("non-UTF-8 key accepted")
+	}
+}
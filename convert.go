@@ -0,0 +1,219 @@
+package encoding
+
+import (
+	"math"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// 某类型与中间数据之间的转换器，Encode或Decode为nil时该方向使用默认方案
+type Converter struct {
+	Type   reflect.Type
+	Encode func(reflect.Value) (interface{}, error)
+	Decode func(reflect.Value, interface{}) error
+}
+
+// 内置的转换器，由各编码格式按需启用
+var (
+	// time.Time与Unix秒数，解码时也接受RFC3339格式的文本（未启用转换器时的编码形式）
+	UnixTime = Converter{reflect.TypeOf(time.Time{}), encodeUnixTime, decodeUnixTime}
+	// time.Duration与毫秒数，不足一毫秒的部分编码为浮点数的小数部分（bencode不能表示浮点数，此时编码返回错误）
+	Milliseconds = Converter{reflect.TypeOf(time.Duration(0)), encodeMilliseconds, decodeMilliseconds}
+	// *big.Int与十进制字符串，解码时也接受整数
+	BigInteger = Converter{reflect.TypeOf((*big.Int)(nil)), encodeBigInteger, decodeBigInteger}
+	// net.IP与文本形式，解码时也接受4或16字节的二进制形式
+	IPAddress = Converter{reflect.TypeOf(net.IP(nil)), encodeIPAddress, decodeIPAddress}
+	// url.URL与字符串
+	URLString = Converter{reflect.TypeOf(url.URL{}), encodeURLString, decodeURLString}
+)
+
+// 为某类型注册转换器，其优先于Raw、Marshaler等其他规则；已构建的编解码方案会被丢弃
+//
+// 转换器先于新的一组方案生效，正在构建的方案只会存入被丢弃的旧组，不会留下过期的方案
+func (this *Translator) RegisterConverter(t reflect.Type, enc func(reflect.Value) (interface{}, error), dec func(reflect.Value, interface{}) error) {
+	c := this.store()
+	c.conv.Store(t, Converter{t, enc, dec})
+	atomic.StorePointer(&c.plan, unsafe.Pointer(new(plans)))
+}
+
+// 注册若干转换器
+func (this *Translator) Use(c ...Converter) {
+	for _, x := range c {
+		this.RegisterConverter(x.Type, x.Encode, x.Decode)
+	}
+}
+
+// 获取某类型的转换器
+func (this *Translator) converter(t reflect.Type) (Converter, bool) {
+	c, ok := this.store().conv.Load(t)
+	if !ok {
+		return Converter{}, false
+	}
+	return c.(Converter), true
+}
+
+// 使用转换器编码，空指针、空切片等编码为nil
+func convEncoder(f func(reflect.Value) (interface{}, error)) encoderFunc {
+	return func(s *encodeState, x reflect.Value) (interface{}, error) {
+		switch x.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			if x.IsNil() {
+				return nil, nil
+			}
+		}
+		return f(x)
+	}
+}
+
+// 使用转换器解码，空值将可为nil的类型置零
+func convDecoder(f func(reflect.Value, interface{}) error) decoderFunc {
+	return func(s *decodeState, x reflect.Value, d interface{}) error {
+		if null(d) {
+			switch x.Kind() {
+			case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+				x.Set(reflect.Zero(x.Type()))
+				return nil
+			}
+		}
+		return f(x, s.whole(d))
+	}
+}
+
+func encodeUnixTime(x reflect.Value) (interface{}, error) {
+	return x.Interface().(time.Time).Unix(), nil
+}
+
+func decodeUnixTime(x reflect.Value, d interface{}) error {
+	switch u := d.(type) {
+	case time.Time:
+		x.Set(reflect.ValueOf(u))
+		return nil
+	case string:
+		return decodeTimeText(x, []byte(u))
+	case []byte:
+		return decodeTimeText(x, u)
+	}
+	i, e := integer(d)
+	if e != nil {
+		return e
+	}
+	x.Set(reflect.ValueOf(time.Unix(i, 0)))
+	return nil
+}
+
+func decodeTimeText(x reflect.Value, b []byte) error {
+	var t time.Time
+	if t.UnmarshalText(b) != nil {
+		return UnmatchedType
+	}
+	x.Set(reflect.ValueOf(t))
+	return nil
+}
+
+func encodeMilliseconds(x reflect.Value) (interface{}, error) {
+	if n := x.Int(); n%int64(time.Millisecond) != 0 {
+		return float64(n) / float64(time.Millisecond), nil
+	}
+	return x.Int() / int64(time.Millisecond), nil
+}
+
+func decodeMilliseconds(x reflect.Value, d interface{}) error {
+	if f, ok := d.(float64); ok && f != math.Trunc(f) {
+		f = math.Round(f * float64(time.Millisecond))
+		if f < math.MinInt64 || f >= math.MaxInt64 {
+			return NumberOverflow
+		}
+		x.SetInt(int64(f))
+		return nil
+	}
+	i, e := integer(d)
+	if e != nil {
+		return e
+	}
+	if i > math.MaxInt64/int64(time.Millisecond) || i < math.MinInt64/int64(time.Millisecond) {
+		return NumberOverflow
+	}
+	x.SetInt(i * int64(time.Millisecond))
+	return nil
+}
+
+func encodeBigInteger(x reflect.Value) (interface{}, error) {
+	return x.Interface().(*big.Int).String(), nil
+}
+
+func decodeBigInteger(x reflect.Value, d interface{}) error {
+	b := new(big.Int)
+	switch u := d.(type) {
+	case string:
+		if _, ok := b.SetString(u, 10); !ok {
+			return UnmatchedType
+		}
+	case []byte:
+		if _, ok := b.SetString(string(u), 10); !ok {
+			return UnmatchedType
+		}
+	case uint64:
+		b.SetUint64(u)
+	default:
+		i, e := integer(d)
+		if e != nil {
+			return e
+		}
+		b.SetInt64(i)
+	}
+	x.Set(reflect.ValueOf(b))
+	return nil
+}
+
+func encodeIPAddress(x reflect.Value) (interface{}, error) {
+	return x.Interface().(net.IP).String(), nil
+}
+
+func decodeIPAddress(x reflect.Value, d interface{}) error {
+	var ip net.IP
+	switch u := d.(type) {
+	case string:
+		ip = net.ParseIP(u)
+	case []byte:
+		if len(u) == net.IPv4len || len(u) == net.IPv6len {
+			ip = append(net.IP(nil), u...)
+		} else {
+			ip = net.ParseIP(string(u))
+		}
+	default:
+		return UnmatchedType
+	}
+	if ip == nil {
+		return UnmatchedType
+	}
+	x.Set(reflect.ValueOf(ip).Convert(x.Type()))
+	return nil
+}
+
+func encodeURLString(x reflect.Value) (interface{}, error) {
+	u := x.Interface().(url.URL)
+	return u.String(), nil
+}
+
+func decodeURLString(x reflect.Value, d interface{}) error {
+	var s string
+	switch u := d.(type) {
+	case string:
+		s = u
+	case []byte:
+		s = string(u)
+	default:
+		return UnmatchedType
+	}
+	u, e := url.Parse(s)
+	if e != nil {
+		return UnmatchedType
+	}
+	x.Set(reflect.ValueOf(*u))
+	return nil
+}
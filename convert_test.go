@@ -0,0 +1,81 @@
+package encoding
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type converted struct {
+	D   time.Duration `test:"d"`
+	N   *big.Int      `test:"n"`
+	IP  net.IP        `test:"ip"`
+	URL url.URL       `test:"url"`
+}
+
+func TestConverters(t *testing.T) {
+	x := NewTranslator("test", nil)
+	x.Use(Milliseconds, BigInteger, IPAddress, URLString)
+	n, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	u, _ := url.Parse("http://a/b?c=d")
+	for _, c := range []struct {
+		in   converted
+		want []Attr
+	}{
+		{converted{1500 * time.Millisecond, n, net.ParseIP("1.2.3.4"), *u}, []Attr{
+			{"d", int64(1500)}, {"n", "123456789012345678901234567890"}, {"ip", "1.2.3.4"}, {"url", "http://a/b?c=d"},
+		}},
+		{converted{1500 * time.Microsecond, big.NewInt(-1), net.ParseIP("::1"), url.URL{}}, []Attr{
+			{"d", 1.5}, {"n", "-1"}, {"ip", "::1"}, {"url", ""},
+		}},
+		{converted{}, []Attr{{"d", int64(0)}, {"n", nil}, {"ip", nil}, {"url", ""}}},
+	} {
+		d, e := x.Encode(reflect.ValueOf(c.in))
+		if e != nil || !reflect.DeepEqual(d, c.want) {
+			t.Errorf("Encode(%+v) = %#v, %v", c.in, d, e)
+		}
+	}
+	for _, c := range []struct {
+		in   map[string]interface{}
+		want converted
+	}{
+		{map[string]interface{}{"d": int64(2), "n": "-5", "ip": "10.0.0.1", "url": "http://a/b?c=d"},
+			converted{2 * time.Millisecond, big.NewInt(-5), net.ParseIP("10.0.0.1"), *u}},
+		{map[string]interface{}{"d": 0.25, "n": int64(7), "ip": []byte{10, 0, 0, 2}, "url": []byte("http://a/b?c=d")},
+			converted{250 * time.Microsecond, big.NewInt(7), net.IP{10, 0, 0, 2}, *u}},
+		{map[string]interface{}{"d": float64(3), "n": uint64(1 << 63), "ip": []byte("::1")},
+			converted{3 * time.Millisecond, new(big.Int).SetUint64(1 << 63), net.ParseIP("::1"), url.URL{}}},
+	} {
+		var y converted
+		if e := x.Decode(reflect.ValueOf(&y).Elem(), c.in); e != nil || !reflect.DeepEqual(y, c.want) {
+			t.Errorf("Decode(%v) = %+v, %v", c.in, y, e)
+		}
+	}
+	for _, in := range []map[string]interface{}{
+		{"n": "12x"},
+		{"n": 1.5},
+		{"ip": "1.2.3"},
+		{"ip": int64(1)},
+		{"url": "%zz"},
+		{"d": "1s"},
+		{"d": 1e300},
+	} {
+		var y converted
+		if e := x.Decode(reflect.ValueOf(&y).Elem(), in); !errors.Is(e, UnmatchedType) {
+			t.Errorf("Decode(%v) = %v, want UnmatchedType", in, e)
+		}
+	}
+}
+
+func TestConverterOff(t *testing.T) {
+	x := NewTranslator("test", nil)
+	x.Use(Milliseconds)
+	x.RegisterConverter(Milliseconds.Type, nil, nil)
+	if d, e := x.Encode(reflect.ValueOf(time.Second)); e != nil || d != int64(time.Second) {
+		t.Fatalf("Encode = %#v, %v", d, e)
+	}
+}
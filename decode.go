@@ -0,0 +1,569 @@
+package encoding
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// 某类型的解码方案
+type decoderFunc func(*decodeState, reflect.Value, interface{}) error
+
+// 结构体某字段的解码方案
+type fieldDecoder struct {
+	i    []int       // 字段的索引序列
+	key  string      // 中间数据的键
+	name string      // 字段名
+	omit bool        // 是否具有omitempty属性
+	need bool        // 是否具有required属性
+	fill bool        // 是否具有default属性
+	def  interface{} // default属性的值解析成的中间数据
+	dec  decoderFunc
+}
+
+// 解码并填充某值，中间数据的类型与值的类型相同时直接赋值
+//
+// 解码器产生的*Node逐层转换为中间数据，其成员在解码到对应的值时再转换；
+// 同一列表、字典或对象节点再次解码到同一类型时复用第一次的结果，共享的节点不会被反复展开
+func (this *decodeState) decode(f decoderFunc, x reflect.Value, d interface{}) error {
+	if n, ok := d.(*Node); ok {
+		if x.Type() == nodeType && n != nil {
+			x.Set(reflect.ValueOf(*n))
+			return nil
+		}
+		if n != nil {
+			defer func(o int64) { this.offset = o }(this.offset)
+			this.offset = n.Offset
+			switch n.Kind {
+			case ListNode, DictNode, ObjectNode:
+				k := memo{n, x.Type()}
+				if v, ok := this.memo[k]; ok {
+					x.Set(v)
+					return nil
+				}
+				if e := this.decode(f, x, n.shallow(this.t.Class)); e != nil || !x.CanInterface() {
+					return e
+				}
+				if this.memo == nil {
+					this.memo = make(map[memo]reflect.Value)
+				}
+				v := reflect.New(x.Type()).Elem()
+				v.Set(x)
+				this.memo[k] = v
+				return nil
+			}
+		}
+		d = n.shallow(this.t.Class)
+	}
+	if d != nil && x.Type() == reflect.TypeOf(d) {
+		x.Set(reflect.ValueOf(this.whole(d)))
+		return nil
+	}
+	e := f(this, x, d)
+	if e == UnmatchedType || e == NumberOverflow || e == PrecisionLoss {
+		return this.unmatched(x.Type(), d, e)
+	}
+	return e
+}
+
+// 判断类型（或其指向的类型）是否为可使用string属性的数值或布尔类型
+func quotable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// 按类型（或其指向的类型）的种类将字符串解析为中间数据，无法解析时原样返回
+func literal(t reflect.Type, s string) interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, e := strconv.ParseBool(s); e == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, e := strconv.ParseInt(s, 10, 64); e == nil {
+			return i
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u, e := strconv.ParseUint(s, 10, 64); e == nil {
+			return u
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, e := strconv.ParseFloat(s, 64); e == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// 解码以字符串表示的数值和布尔值，也接受未加引号的值，用于string属性和map的键
+func quoteDecoder(t reflect.Type, f decoderFunc) decoderFunc {
+	return func(s *decodeState, x reflect.Value, d interface{}) error {
+		switch u := d.(type) {
+		case string:
+			d = literal(t, u)
+		case []byte:
+			d = literal(t, string(u))
+		}
+		return s.decode(f, x, d)
+	}
+}
+
+// 判断中间数据是否表示空值
+func null(d interface{}) bool {
+	if d == nil {
+		return true
+	}
+	_, ok := d.(Undefined)
+	return ok
+}
+
+// 构建某类型的解码方案
+func (this *Translator) newDecoder(t reflect.Type) decoderFunc {
+	if t == nodeType {
+		return nodeDecoder
+	}
+	if c, ok := this.converter(t); ok && c.Decode != nil {
+		return convDecoder(c.Decode)
+	}
+	if t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface {
+		if reflect.PtrTo(t).Implements(unmarshalerType) {
+			return unmarshalDecoder
+		}
+		if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+			return textDecoder(this.kindDecoder(t))
+		}
+	}
+	return this.kindDecoder(t)
+}
+
+// 按类型的种类构建解码方案
+func (this *Translator) kindDecoder(t reflect.Type) decoderFunc {
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolDecoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intDecoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintDecoder
+	case reflect.Float32, reflect.Float64:
+		return floatDecoder
+	case reflect.Complex64, reflect.Complex128:
+		return complexDecoder
+	case reflect.String:
+		return stringDecoder
+	case reflect.Interface:
+		return interfaceDecoder
+	case reflect.Ptr:
+		return this.ptrDecoder(t)
+	case reflect.Slice:
+		return this.sliceDecoder(t)
+	case reflect.Array:
+		return this.arrayDecoder(t)
+	case reflect.Map:
+		return this.mapDecoder(t)
+	case reflect.Struct:
+		return this.structDecoder(t)
+	}
+	return unmatchedDecoder
+}
+
+func unmarshalDecoder(s *decodeState, x reflect.Value, d interface{}) error {
+	return x.Addr().Interface().(Unmarshaler).UnmarshalData(s.whole(d))
+}
+
+// 中间数据为字符串时使用UnmarshalText方法，否则使用后备方案
+func textDecoder(f decoderFunc) decoderFunc {
+	return func(s *decodeState, x reflect.Value, d interface{}) error {
+		switch u := d.(type) {
+		case string:
+			return x.Addr().Interface().(textUnmarshaler).UnmarshalText([]byte(u))
+		case []byte:
+			return x.Addr().Interface().(textUnmarshaler).UnmarshalText(u)
+		}
+		return f(s, x, d)
+	}
+}
+
+func unmatchedDecoder(s *decodeState, x reflect.Value, d interface{}) error {
+	return UnmatchedType
+}
+
+func boolDecoder(s *decodeState, x reflect.Value, d interface{}) error {
+	if u, ok := d.(bool); ok {
+		x.SetBool(u)
+		return nil
+	}
+	return UnmatchedType
+}
+
+// 有符号整数可由任意数值安全转换而来
+func intDecoder(s *decodeState, x reflect.Value, d interface{}) error {
+	i, e := integer(d)
+	if e != nil {
+		return e
+	}
+	if x.OverflowInt(i) {
+		return NumberOverflow
+	}
+	x.SetInt(i)
+	return nil
+}
+
+// 将任意数值安全转换为int64
+func integer(d interface{}) (int64, error) {
+	switch u := d.(type) {
+	case int64:
+		return u, nil
+	case uint64:
+		if u > math.MaxInt64 {
+			return 0, NumberOverflow
+		}
+		return int64(u), nil
+	case float64:
+		if u != math.Trunc(u) {
+			return 0, PrecisionLoss
+		}
+		if u < math.MinInt64 || u >= math.MaxInt64 {
+			return 0, NumberOverflow
+		}
+		return int64(u), nil
+	}
+	return 0, UnmatchedType
+}
+
+// 无符号整数可由任意数值安全转换而来
+func uintDecoder(s *decodeState, x reflect.Value, d interface{}) error {
+	var i uint64
+	switch u := d.(type) {
+	case int64:
+		if u < 0 {
+			return NumberOverflow
+		}
+		i = uint64(u)
+	case uint64:
+		i = u
+	case float64:
+		if u != math.Trunc(u) {
+			return PrecisionLoss
+		}
+		if u < 0 || u >= math.MaxUint64 {
+			return NumberOverflow
+		}
+		i = uint64(u)
+	default:
+		return UnmatchedType
+	}
+	if x.OverflowUint(i) {
+		return NumberOverflow
+	}
+	x.SetUint(i)
+	return nil
+}
+
+// 浮点数可由任意数值转换而来，整数须能被精确表示
+func floatDecoder(s *decodeState, x reflect.Value, d interface{}) error {
+	var f float64
+	switch u := d.(type) {
+	case int64:
+		f = float64(u)
+		if u != math.MinInt64 && (f >= math.MaxInt64 || int64(f) != u) {
+			return PrecisionLoss
+		}
+	case uint64:
+		f = float64(u)
+		if f >= math.MaxUint64 || uint64(f) != u {
+			return PrecisionLoss
+		}
+	case float64:
+		f = u
+	default:
+		return UnmatchedType
+	}
+	if x.OverflowFloat(f) {
+		return NumberOverflow
+	}
+	if x.Kind() == reflect.Float32 {
+		if _, ok := d.(float64); !ok && float64(float32(f)) != f {
+			return PrecisionLoss
+		}
+	}
+	x.SetFloat(f)
+	return nil
+}
+
+func complexDecoder(s *decodeState, x reflect.Value, d interface{}) error {
+	if u, ok := d.(complex128); ok {
+		x.SetComplex(u)
+		return nil
+	}
+	return UnmatchedType
+}
+
+func stringDecoder(s *decodeState, x reflect.Value, d interface{}) error {
+	switch u := d.(type) {
+	case string:
+		x.SetString(u)
+		return nil
+	case []byte:
+		x.SetString(string(u))
+		return nil
+	}
+	return UnmatchedType
+}
+
+func interfaceDecoder(s *decodeState, x reflect.Value, d interface{}) error {
+	if null(d) {
+		x.Set(reflect.Zero(x.Type()))
+		return nil
+	}
+	v := reflect.ValueOf(s.whole(d))
+	if !v.Type().AssignableTo(x.Type()) {
+		return UnmatchedType
+	}
+	x.Set(v)
+	return nil
+}
+
+func (this *Translator) ptrDecoder(t reflect.Type) decoderFunc {
+	f := this.decoder(t.Elem())
+	return func(s *decodeState, x reflect.Value, d interface{}) error {
+		if null(d) {
+			x.Set(reflect.Zero(t))
+			return nil
+		}
+		if x.IsNil() {
+			x.Set(reflect.New(t.Elem()))
+		}
+		return s.decode(f, x.Elem(), d)
+	}
+}
+
+func (this *Translator) sliceDecoder(t reflect.Type) decoderFunc {
+	f := this.decoder(t.Elem())
+	b := t.Elem().Kind() == reflect.Uint8
+	return func(s *decodeState, x reflect.Value, d interface{}) error {
+		if null(d) {
+			x.Set(reflect.Zero(t))
+			return nil
+		}
+		if b {
+			switch u := d.(type) {
+			case string:
+				x.SetBytes([]byte(u))
+				return nil
+			case []byte:
+				x.SetBytes(u)
+				return nil
+			}
+		}
+		if u, ok := d.([]interface{}); ok {
+			n := reflect.MakeSlice(t, len(u), len(u))
+			for i := 0; i < len(u); i++ {
+				s.push(step{i: i})
+				if e := s.decode(f, n.Index(i), u[i]); e != nil {
+					return e
+				}
+				s.pop()
+			}
+			x.Set(n)
+			return nil
+		}
+		return UnmatchedType
+	}
+}
+
+func (this *Translator) arrayDecoder(t reflect.Type) decoderFunc {
+	f := this.decoder(t.Elem())
+	return func(s *decodeState, x reflect.Value, d interface{}) error {
+		if u, ok := d.([]interface{}); ok {
+			l := x.Len()
+			if l > len(u) {
+				l = len(u)
+			}
+			for i := 0; i < l; i++ {
+				s.push(step{i: i})
+				if e := s.decode(f, x.Index(i), u[i]); e != nil {
+					return e
+				}
+				s.pop()
+			}
+			return nil
+		}
+		return UnmatchedType
+	}
+}
+
+func (this *Translator) mapDecoder(t reflect.Type) decoderFunc {
+	f, g := this.decoder(t.Key()), this.decoder(t.Elem())
+	z := t.Key()
+	k := z.Kind() == reflect.String || reflect.PtrTo(z).Implements(textUnmarshalerType)
+	if quotable(z) {
+		f, k = quoteDecoder(z, f), true
+	}
+	return func(s *decodeState, x reflect.Value, d interface{}) error {
+		if null(d) {
+			x.Set(reflect.Zero(t))
+			return nil
+		}
+		switch u := d.(type) {
+		case []Item:
+			if x.IsNil() {
+				x.Set(reflect.MakeMapWithSize(t, len(u)))
+			}
+			for i := 0; i < len(u); i++ {
+				s.push(step{k: reflect.ValueOf(u[i].K), i: i})
+				K := reflect.New(z).Elem()
+				if e := s.decode(f, K, u[i].K); e != nil {
+					return e
+				}
+				V := reflect.New(t.Elem()).Elem()
+				if e := s.decode(g, V, u[i].V); e != nil {
+					return e
+				}
+				s.pop()
+				x.SetMapIndex(K, V)
+			}
+			return nil
+		case map[string]interface{}:
+			if !k {
+				break
+			}
+			if x.IsNil() {
+				x.Set(reflect.MakeMapWithSize(t, len(u)))
+			}
+			for i, j := range u {
+				s.push(step{k: reflect.ValueOf(i)})
+				K := reflect.New(z).Elem()
+				if e := s.decode(f, K, i); e != nil {
+					return e
+				}
+				V := reflect.New(t.Elem()).Elem()
+				if e := s.decode(g, V, j); e != nil {
+					return e
+				}
+				s.pop()
+				x.SetMapIndex(K, V)
+			}
+			return nil
+		}
+		return UnmatchedType
+	}
+}
+
+func (this *Translator) structDecoder(t reflect.Type) decoderFunc {
+	label := this.GetLabel(t)
+	field := make([]fieldDecoder, 0, len(label))
+	known := make(map[string]bool)
+	var rest *fieldDecoder
+	for i := 0; i < len(label); i++ {
+		f := t.FieldByIndex(label[i].I)
+		if remain(&label[i], f.Type) {
+			rest = &fieldDecoder{i: label[i].I, name: f.Name, dec: this.decoder(f.Type.Elem())}
+			continue
+		}
+		known[label[i].Name()] = true
+		dec := this.decoder(f.Type)
+		if label[i].Quoted && quotable(f.Type) {
+			dec = quoteDecoder(f.Type, dec)
+		}
+		u := fieldDecoder{
+			i:    label[i].I,
+			key:  label[i].Name(),
+			name: f.Name,
+			omit: label[i].OmitEmpty,
+			need: label[i].Required,
+			dec:  dec,
+		}
+		if label[i].Default != nil {
+			u.fill, u.def = true, literal(f.Type, *label[i].Default)
+		}
+		field = append(field, u)
+	}
+	return func(s *decodeState, x reflect.Value, d interface{}) error {
+		u, ok := d.(map[string]interface{})
+		if !ok {
+			return UnmatchedType
+		}
+		if rest != nil {
+			if e := rest.remain(s, x, u, known); e != nil {
+				return e
+			}
+		} else if s.t.Strict {
+			for K := range u {
+				if !known[K] && (s.t.Class == "" || K != s.t.Class) {
+					return &UnknownFieldError{trace(s.path), K, t}
+				}
+			}
+		}
+		for i := 0; i < len(field); i++ {
+			w, ok := u[field[i].key]
+			if !ok && field[i].need {
+				return &MissingFieldError{trace(s.path), field[i].key, t}
+			}
+			def := !ok && field[i].fill
+			if def {
+				w, ok = field[i].def, true
+			}
+			if ok {
+				v := fieldAlloc(x, field[i].i)
+				s.push(step{f: field[i].name})
+				o := s.offset
+				if def {
+					s.offset = -1 // 默认值不来自输入
+				}
+				e := s.decode(field[i].dec, v, w)
+				s.offset = o
+				if e != nil {
+					return e
+				}
+				s.pop()
+			} else if field[i].omit {
+				if v, ok := fieldOf(x, field[i].i); ok {
+					v.Set(reflect.Zero(v.Type()))
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// 将未匹配任何字段的键值对收集到remain字段中，表示类名的键不收集
+func (this *fieldDecoder) remain(s *decodeState, x reflect.Value, u map[string]interface{}, known map[string]bool) error {
+	var m reflect.Value
+	s.push(step{f: this.name})
+	defer s.pop()
+	for K, V := range u {
+		if known[K] || s.t.Class != "" && K == s.t.Class {
+			continue
+		}
+		if !m.IsValid() {
+			v := fieldAlloc(x, this.i)
+			m = reflect.MakeMap(v.Type())
+			v.Set(m)
+		}
+		v := reflect.New(m.Type().Elem()).Elem()
+		s.push(step{k: reflect.ValueOf(K)})
+		if e := s.decode(this.dec, v, V); e != nil {
+			return e
+		}
+		s.pop()
+		m.SetMapIndex(reflect.ValueOf(K).Convert(m.Type().Key()), v)
+	}
+	if !m.IsValid() {
+		if v, ok := fieldOf(x, this.i); ok {
+			v.Set(reflect.Zero(v.Type()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package encoding
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNumberCoercion(t *testing.T) {
+	x := NewTranslator("test", nil)
+	for _, c := range []struct {
+		ptr  interface{} // 指向目标的指针
+		d    interface{}
+		want interface{} // 期望的结果或错误
+	}{
+		{new(int), float64(-5), -5},
+		{new(int), uint64(7), 7},
+		{new(int8), int64(127), int8(127)},
+		{new(int8), int64(128), NumberOverflow},
+		{new(int8), float64(-129), NumberOverflow},
+		{new(int), float64(1.5), PrecisionLoss},
+		{new(int64), uint64(math.MaxUint64), NumberOverflow},
+		{new(int64), float64(1 << 63), NumberOverflow},
+		{new(int64), int64(math.MinInt64), int64(math.MinInt64)},
+		{new(uint32), float64(7), uint32(7)},
+		{new(uint32), int64(-1), NumberOverflow},
+		{new(uint32), int64(1 << 32), NumberOverflow},
+		{new(uint64), float64(-1), NumberOverflow},
+		{new(uint64), float64(0.5), PrecisionLoss},
+		{new(uint64), uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{new(float64), int64(3), float64(3)},
+		{new(float64), int64(1<<53 + 1), PrecisionLoss},
+		{new(float64), uint64(math.MaxUint64), PrecisionLoss},
+		{new(float32), int64(1 << 24), float32(1 << 24)},
+		{new(float32), int64(1<<24 + 1), PrecisionLoss},
+		{new(float32), float64(0.1), float32(0.1)},
+		{new(float32), float64(1e39), NumberOverflow},
+		{new(int), "1", UnmatchedType},
+		{new(float64), true, UnmatchedType},
+	} {
+		v := reflect.ValueOf(c.ptr).Elem()
+		e := x.Decode(v, c.d)
+		if want, ok := c.want.(error); ok {
+			if !errors.Is(e, want) {
+				t.Errorf("%T <- %T(%v): got %v, want %v", c.ptr, c.d, c.d, e, want)
+			}
+			continue
+		}
+		if e != nil || v.Interface() != reflect.ValueOf(c.want).Convert(v.Type()).Interface() {
+			t.Errorf("%T <- %T(%v): got %v, %v; want %v", c.ptr, c.d, c.d, v.Interface(), e, c.want)
+		}
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	x := NewTranslator("test", nil)
+	for _, c := range []struct {
+		ptr  interface{}
+		d    map[string]interface{}
+		want interface{}
+	}{
+		{new(map[int]string), map[string]interface{}{"-3": "a", "10": "b"}, map[int]string{-3: "a", 10: "b"}},
+		{new(map[uint32]float64), map[string]interface{}{"7": 1.5}, map[uint32]float64{7: 1.5}},
+		{new(map[bool]int), map[string]interface{}{"true": int64(1)}, map[bool]int{true: 1}},
+		{new(map[float64]int), map[string]interface{}{"0.5": int64(1)}, map[float64]int{0.5: 1}},
+		{new(map[int8]int), map[string]interface{}{"300": int64(1)}, NumberOverflow},
+		{new(map[int]int), map[string]interface{}{"x": int64(1)}, UnmatchedType},
+		{new(map[uint]int), map[string]interface{}{"-1": int64(1)}, UnmatchedType},
+	} {
+		v := reflect.ValueOf(c.ptr).Elem()
+		e := x.Decode(v, c.d)
+		if want, ok := c.want.(error); ok {
+			if !errors.Is(e, want) {
+				t.Errorf("%T <- %v: got %v, want %v", c.ptr, c.d, e, want)
+			}
+		} else if e != nil || !reflect.DeepEqual(v.Interface(), c.want) {
+			t.Errorf("%T <- %v: got %v, %v", c.ptr, c.d, v.Interface(), e)
+		}
+	}
+}
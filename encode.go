@@ -0,0 +1,394 @@
+package encoding
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// 某类型的编码方案
+type encoderFunc func(*encodeState, reflect.Value) (interface{}, error)
+
+// 结构体某字段的编码方案
+type fieldEncoder struct {
+	i    []int  // 字段的索引序列
+	key  string // 编码后的键
+	name string // 字段名
+	omit bool   // 是否具有omitempty属性
+	zero bool   // 是否具有omitzero属性
+	rest bool   // 是否为收集未知键的remain字段
+	enc  encoderFunc
+}
+
+// 构建某类型的编码方案
+func (this *Translator) newEncoder(t reflect.Type) encoderFunc {
+	if t == nodeType {
+		return nodeEncoder
+	}
+	if c, ok := this.converter(t); ok && c.Encode != nil {
+		return convEncoder(c.Encode)
+	}
+	if _, ok := this.Raw[t]; ok {
+		return rawEncoder
+	}
+	if t.Kind() != reflect.Interface {
+		if t.Implements(marshalerType) {
+			return marshalEncoder
+		}
+		if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(marshalerType) {
+			return addrEncoder(marshalEncoder, this.kindEncoder(t))
+		}
+		if t.Implements(textMarshalerType) {
+			return textEncoder
+		}
+		if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(textMarshalerType) {
+			return addrEncoder(textEncoder, this.kindEncoder(t))
+		}
+	}
+	return this.kindEncoder(t)
+}
+
+// 按类型的种类构建编码方案
+func (this *Translator) kindEncoder(t reflect.Type) encoderFunc {
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolEncoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intEncoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintEncoder
+	case reflect.Float32, reflect.Float64:
+		return floatEncoder
+	case reflect.Complex64, reflect.Complex128:
+		return complexEncoder
+	case reflect.String:
+		return stringEncoder
+	case reflect.Interface:
+		return interfaceEncoder
+	case reflect.Ptr:
+		return this.ptrEncoder(t)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return bytesEncoder
+		}
+		return this.sliceEncoder(t)
+	case reflect.Array:
+		return this.arrayEncoder(t)
+	case reflect.Map:
+		return this.mapEncoder(t)
+	case reflect.Struct:
+		return this.structEncoder(t)
+	}
+	return unsupportEncoder
+}
+
+// 可寻址时使用指针的方法，否则使用后备方案
+func addrEncoder(f, g encoderFunc) encoderFunc {
+	return func(s *encodeState, x reflect.Value) (interface{}, error) {
+		if x.CanAddr() {
+			return f(s, x.Addr())
+		}
+		return g(s, x)
+	}
+}
+
+func rawEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	return x.Interface(), nil
+}
+
+func marshalEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	if x.Kind() == reflect.Ptr && x.IsNil() {
+		return nil, nil
+	}
+	return x.Interface().(Marshaler).MarshalData()
+}
+
+func textEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	if x.Kind() == reflect.Ptr && x.IsNil() {
+		return nil, nil
+	}
+	b, e := x.Interface().(textMarshaler).MarshalText()
+	if e != nil {
+		return nil, e
+	}
+	return string(b), nil
+}
+
+// 将数值和布尔值编码为字符串，用于string属性和map的键
+func quoteEncoder(f encoderFunc) encoderFunc {
+	return func(s *encodeState, x reflect.Value) (interface{}, error) {
+		v, e := f(s, x)
+		if e != nil {
+			return nil, e
+		}
+		switch u := v.(type) {
+		case bool:
+			return strconv.FormatBool(u), nil
+		case int64:
+			return strconv.FormatInt(u, 10), nil
+		case uint64:
+			return strconv.FormatUint(u, 10), nil
+		case float64:
+			return strconv.FormatFloat(u, 'g', -1, 64), nil
+		}
+		return v, nil
+	}
+}
+
+func boolEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	return x.Bool(), nil
+}
+
+func intEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	return x.Int(), nil
+}
+
+func uintEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	return x.Uint(), nil
+}
+
+func floatEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	return x.Float(), nil
+}
+
+func complexEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	return x.Complex(), nil
+}
+
+func stringEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	return x.String(), nil
+}
+
+func bytesEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	if x.IsNil() {
+		return nil, nil
+	}
+	return x.Bytes(), nil
+}
+
+func unsupportEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	return nil, UnsupportType
+}
+
+func interfaceEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	if x.IsNil() {
+		return nil, nil
+	}
+	x = x.Elem()
+	return s.t.encoder(x.Type())(s, x)
+}
+
+func (this *Translator) ptrEncoder(t reflect.Type) encoderFunc {
+	f := this.encoder(t.Elem())
+	return func(s *encodeState, x reflect.Value) (interface{}, error) {
+		if x.IsNil() {
+			return nil, nil
+		}
+		k := visit{x.Pointer(), t, 0}
+		if r, ok := s.enter(k); !ok {
+			return s.cycle(r, t)
+		}
+		defer delete(s.seen, k)
+		return f(s, x.Elem())
+	}
+}
+
+func (this *Translator) sliceEncoder(t reflect.Type) encoderFunc {
+	f := this.arrayEncoder(t)
+	return func(s *encodeState, x reflect.Value) (interface{}, error) {
+		if x.IsNil() {
+			return nil, nil
+		}
+		k := visit{x.Pointer(), t, x.Len()}
+		if r, ok := s.enter(k); !ok {
+			return s.cycle(r, t)
+		}
+		defer delete(s.seen, k)
+		return f(s, x)
+	}
+}
+
+func (this *Translator) arrayEncoder(t reflect.Type) encoderFunc {
+	f := this.encoder(t.Elem())
+	return func(s *encodeState, x reflect.Value) (interface{}, error) {
+		l := x.Len()
+		u := make([]interface{}, 0, l)
+		s.level++
+		for i := 0; i < l; i++ {
+			s.push(step{i: i})
+			v, e := f(s, x.Index(i))
+			if e != nil {
+				return nil, e
+			}
+			s.pop()
+			u = append(u, v)
+		}
+		s.level--
+		return u, nil
+	}
+}
+
+func (this *Translator) mapEncoder(t reflect.Type) encoderFunc {
+	f, g := this.encoder(t.Key()), this.encoder(t.Elem())
+	if quotable(t.Key()) {
+		f = quoteEncoder(f)
+	}
+	return func(s *encodeState, x reflect.Value) (interface{}, error) {
+		if x.IsNil() {
+			return nil, nil
+		}
+		k := visit{x.Pointer(), t, 0}
+		if r, ok := s.enter(k); !ok {
+			return s.cycle(r, t)
+		}
+		defer delete(s.seen, k)
+		u := make([]Item, 0, x.Len())
+		s.level++
+		for i := x.MapRange(); i.Next(); {
+			s.push(step{k: i.Key()})
+			K, e := f(s, i.Key())
+			if e != nil {
+				return nil, e
+			}
+			V, e := g(s, i.Value())
+			if e != nil {
+				return nil, e
+			}
+			s.pop()
+			u = append(u, Item{K, V})
+		}
+		s.level--
+		sort.Slice(u, func(i, j int) bool { return less(u[i].K, u[j].K) })
+		return u, nil
+	}
+}
+
+func (this *Translator) structEncoder(t reflect.Type) encoderFunc {
+	label := this.GetLabel(t)
+	field := make([]fieldEncoder, 0, len(label))
+	known := make(map[string]bool)
+	for i := 0; i < len(label); i++ {
+		f := t.FieldByIndex(label[i].I)
+		if remain(&label[i], f.Type) {
+			field = append(field, fieldEncoder{
+				i:    label[i].I,
+				name: f.Name,
+				rest: true,
+				enc:  this.encoder(f.Type.Elem()),
+			})
+			continue
+		}
+		known[label[i].Name()] = true
+		enc := this.encoder(f.Type)
+		if label[i].Quoted && quotable(f.Type) {
+			enc = quoteEncoder(enc)
+		}
+		field = append(field, fieldEncoder{
+			i:    label[i].I,
+			key:  label[i].Name(),
+			name: f.Name,
+			omit: label[i].OmitEmpty,
+			zero: label[i].OmitZero,
+			enc:  enc,
+		})
+	}
+	return func(s *encodeState, x reflect.Value) (interface{}, error) {
+		u := make([]Attr, 0, len(field))
+		s.level++
+		for i := 0; i < len(field); i++ {
+			v, ok := fieldOf(x, field[i].i)
+			if !ok || field[i].omit && ZeroWith(v, !s.t.OnlyNil) || field[i].zero && ZeroWith(v, false) {
+				continue
+			}
+			if field[i].rest {
+				r, e := field[i].remain(s, v, known)
+				if e != nil {
+					return nil, e
+				}
+				u = append(u, r...)
+				continue
+			}
+			s.push(step{f: field[i].name})
+			V, e := field[i].enc(s, v)
+			if e != nil {
+				return nil, e
+			}
+			s.pop()
+			u = append(u, Attr{field[i].key, V})
+		}
+		s.level--
+		if s.t.Sorted {
+			sort.SliceStable(u, func(i, j int) bool { return u[i].K < u[j].K })
+		}
+		return u, nil
+	}
+}
+
+// 比较map编码后两个键的顺序，字符串和字节串按字节序，数值按大小，其余按其字符串形式
+func less(a, b interface{}) bool {
+	switch x := a.(type) {
+	case string:
+		switch y := b.(type) {
+		case string:
+			return x < y
+		case []byte:
+			return x < string(y)
+		}
+	case []byte:
+		switch y := b.(type) {
+		case string:
+			return string(x) < y
+		case []byte:
+			return bytes.Compare(x, y) < 0
+		}
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return x < y
+		case uint64:
+			return x < 0 || uint64(x) < y
+		}
+	case uint64:
+		switch y := b.(type) {
+		case int64:
+			return y >= 0 && x < uint64(y)
+		case uint64:
+			return x < y
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			return x < y
+		}
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+// 将remain字段中与已知字段不重名的键值对按键排序后编码
+func (this *fieldEncoder) remain(s *encodeState, x reflect.Value, known map[string]bool) ([]Attr, error) {
+	if x.Len() == 0 {
+		return nil, nil
+	}
+	k := make([]string, 0, x.Len())
+	for i := x.MapRange(); i.Next(); {
+		if K := i.Key().String(); !known[K] {
+			k = append(k, K)
+		}
+	}
+	sort.Strings(k)
+	u := make([]Attr, 0, len(k))
+	s.push(step{f: this.name})
+	for _, K := range k {
+		y := reflect.ValueOf(K).Convert(x.Type().Key())
+		s.push(step{k: y})
+		V, e := this.enc(s, x.MapIndex(y))
+		if e != nil {
+			return nil, e
+		}
+		s.pop()
+		u = append(u, Attr{K, V})
+	}
+	s.pop()
+	return u, nil
+}
@@ -0,0 +1,87 @@
+package encoding
+
+import (
+	"reflect"
+	"testing"
+)
+
+type order struct {
+	Z    int                    `test:"z"`
+	A    int                    `test:"a"`
+	Rest map[string]interface{} `test:",remain"`
+}
+
+func TestSortedOutput(t *testing.T) {
+	x := NewTranslator("test", nil)
+	m := map[string]int{}
+	for _, k := range []string{"b", "a", "B", "aa", "\xff", "c", "d", "e", "f", "g"} {
+		m[k] = 1
+	}
+	first, e := x.Encode(reflect.ValueOf(m))
+	if e != nil {
+		t.Fatal(e)
+	}
+	var keys []interface{}
+	for _, u := range first.([]Item) {
+		keys = append(keys, u.K)
+	}
+	if !reflect.DeepEqual(keys, []interface{}{"B", "a", "aa", "b", "c", "d", "e", "f", "g", "\xff"}) {
+		t.Fatalf("keys = %q", keys)
+	}
+	for i := 0; i < 20; i++ {
+		if d, _ := x.Encode(reflect.ValueOf(m)); !reflect.DeepEqual(d, first) {
+			t.Fatal("map encoding is not deterministic")
+		}
+	}
+	d, e := x.Encode(reflect.ValueOf(map[int]bool{10: true, 9: true, -3: true}))
+	if e != nil || !reflect.DeepEqual(d, []Item{{"-3", true}, {"10", true}, {"9", true}}) {
+		t.Fatalf("int keys = %#v, %v", d, e)
+	}
+	v := order{1, 2, map[string]interface{}{"m": int64(3), "b": int64(4)}}
+	for _, c := range []struct {
+		sorted bool
+		want   []Attr
+	}{
+		{false, []Attr{{"z", int64(1)}, {"a", int64(2)}, {"b", int64(4)}, {"m", int64(3)}}},
+		{true, []Attr{{"a", int64(2)}, {"b", int64(4)}, {"m", int64(3)}, {"z", int64(1)}}},
+	} {
+		x.Sorted = c.sorted
+		if d, e := x.Encode(reflect.ValueOf(v)); e != nil || !reflect.DeepEqual(d, c.want) {
+			t.Errorf("sorted %v: %#v, %v", c.sorted, d, e)
+		}
+	}
+}
+
+type chainNode struct {
+	Name string     `test:"name"`
+	Next *chainNode `test:"next,omitempty"`
+}
+
+func TestCycle(t *testing.T) {
+	x := NewTranslator("test", nil)
+	a := &chainNode{Name: "a"}
+	b := &chainNode{Name: "b", Next: a}
+	a.Next = b
+	_, e := x.Encode(reflect.ValueOf(a))
+	c, ok := e.(*CycleError)
+	if !ok || c.Path != "Next.Next" || c.Type != reflect.TypeOf(a) {
+		t.Fatalf("got %v", e)
+	}
+	x.Refer = true
+	d, e := x.Encode(reflect.ValueOf(a))
+	want := []Attr{{"name", "a"}, {"next", []Attr{{"name", "b"}, {"next", Reference{0}}}}}
+	if e != nil || !reflect.DeepEqual(d, want) {
+		t.Fatalf("Refer: %#v, %v", d, e)
+	}
+	// 共享而不成环的值不是循环引用
+	x.Refer = false
+	s := &chainNode{Name: "s"}
+	if _, e = x.Encode(reflect.ValueOf([]*chainNode{s, s})); e != nil {
+		t.Fatalf("shared: %v", e)
+	}
+	m := map[string]interface{}{}
+	m["self"] = m
+	if _, e = x.Encode(reflect.ValueOf(m)); e == nil {
+		t.Fatal("map cycle not detected")
+	}
+}
@@ -1,75 +1,181 @@
 package encoding
 
-import "io"
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
 
-// 用于从Reader接口获取数据，采用panic替代返回错误值
+var (
+	// 退回字节前没有读取过字节
+	ErrUnreadByte = errors.New("encoding: invalid use of UnreadByte")
+	// 要读取的字节数为负数
+	ErrNegativeCount = errors.New("encoding: negative count")
+)
+
+// 带缓冲的字节源，实现io.Reader与io.ByteScanner，出错时返回错误
 type Iterator struct {
 	r    io.Reader
 	m    []byte
-	a, b int
-	o    error
+	a, b int   // 缓冲区中未读数据的起止位置
+	o    error // 下层Reader返回的错误
+	p    int64 // 缓冲区起始处之前已读取的字节数
 }
 
 // 创建并返回一个Iterator
 func NewIterator(r io.Reader) *Iterator {
-	return &Iterator{r, make([]byte, 4096), 0, 0, nil}
+	return &Iterator{r: r, m: make([]byte, 4096)}
 }
 
-// 获取一个字节
-func (this *Iterator) ReadByte() byte {
-	var c byte
-	for {
-		if this.a < this.b {
-			c = this.m[this.a]
-			this.a++
-			break
+// 补充缓冲区直到至少有n个未读字节，n不得超过缓冲区大小，数据不足时返回下层的错误；
+// 空间允许时保留最后读取的一个字节以便退回
+func (this *Iterator) fill(n int) error {
+	if this.b-this.a >= n {
+		return nil
+	}
+	k := this.a
+	if k > 0 {
+		k--
+	}
+	if this.a+n > len(this.m) {
+		if n+this.a-k > len(this.m) {
+			k = this.a
 		}
+		copy(this.m, this.m[k:this.b])
+	} else {
+		k = 0
+	}
+	this.p += int64(k)
+	this.a, this.b = this.a-k, this.b-k
+	for t := 0; this.b-this.a < n; {
 		if this.o != nil {
-			panic(this.o)
+			return this.o
+		}
+		var i int
+		i, this.o = this.r.Read(this.m[this.b:])
+		this.b += i
+		if i != 0 {
+			t = 0
+		} else if t++; t >= 100 {
+			return io.ErrNoProgress
+		}
+	}
+	return nil
+}
+
+// 读取一个字节，输入结束时返回io.EOF
+func (this *Iterator) ReadByte() (byte, error) {
+	if this.a == this.b {
+		if e := this.fill(1); e != nil {
+			return 0, e
 		}
-		this.b, this.o = this.r.Read(this.m)
-		this.a = 0
 	}
-	return c
+	c := this.m[this.a]
+	this.a++
+	return c, nil
 }
 
-// 退回一个字节
-func (this *Iterator) UnreadByte() {
+// 退回最后读取的一个字节
+func (this *Iterator) UnreadByte() error {
+	if this.a == 0 {
+		return ErrUnreadByte
+	}
 	this.a--
+	return nil
+}
+
+// 读取恰好n个字节，数据不足时返回已读取的部分和io.ErrUnexpectedEOF（一个字节也未读到时为io.EOF）
+//
+// 较大的n不会预先分配，内存随实际读到的数据增长，因此伪造的长度不会耗尽内存
+func (this *Iterator) ReadN(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+	if n <= len(this.m) {
+		u := make([]byte, n)
+		i, e := io.ReadFull(this, u)
+		return u[:i], e
+	}
+	w := bytes.NewBuffer(make([]byte, 0, len(this.m)))
+	i, e := io.CopyN(w, this, int64(n))
+	if e == io.EOF && i > 0 {
+		e = io.ErrUnexpectedEOF
+	}
+	return w.Bytes(), e
 }
 
-// 读取一定量字节
-func (this *Iterator) ReadBytes(n int) []byte {
-	u := make([]byte, n)
-	for v := u; ; {
-		if this.a+n <= this.b {
-			copy(v, this.m[this.a:this.a+n])
-			this.a += n
-			break
+// 返回之后的n个字节而不读取，数据不足时返回已有的部分和下层的错误；返回的切片在下次读取前有效
+//
+// n超过缓冲区大小时返回缓冲区能容纳的部分和bufio.ErrBufferFull
+func (this *Iterator) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+	if n > len(this.m) {
+		u, e := this.Peek(len(this.m))
+		if e == nil {
+			e = bufio.ErrBufferFull
 		}
-		x := copy(v, this.m[this.a:this.b])
-		n, v = n-x, v[x:]
-		this.a, this.a = 0, 0
-		if this.o != nil {
-			panic(this.o)
+		return u, e
+	}
+	e := this.fill(n)
+	if this.b-this.a < n {
+		n = this.b - this.a
+	}
+	return this.m[this.a : this.a+n], e
+}
+
+// 跳过n个字节，返回实际跳过的字节数，不足n时同时返回下层的错误
+func (this *Iterator) Discard(n int) (int, error) {
+	if n < 0 {
+		return 0, ErrNegativeCount
+	}
+	for i := n; i > 0; {
+		if this.a == this.b {
+			if e := this.fill(1); e != nil {
+				return n - i, e
+			}
+		}
+		k := this.b - this.a
+		if k > i {
+			k = i
 		}
-		this.b, this.o = this.r.Read(this.m)
+		this.a, i = this.a+k, i-k
+	}
+	return n, nil
+}
+
+// 跳过n个字节，数据不足时返回io.ErrUnexpectedEOF（一个字节也未跳过时为io.EOF）
+func (this *Iterator) Skip(n int) error {
+	i, e := this.Discard(n)
+	if e == io.EOF && i > 0 {
+		e = io.ErrUnexpectedEOF
 	}
-	return u
+	return e
+}
+
+// 返回缓冲区中可不经下层读取即可获取的字节数
+func (this *Iterator) Buffered() int {
+	return this.b - this.a
 }
 
+// 实现io.Reader接口
 func (this *Iterator) Read(data []byte) (int, error) {
-	n := len(data)
-	if this.a+n <= this.b {
-		copy(data, this.m[this.a:this.a+n])
-		this.a += n
-		return n, nil
-	}
-	i := copy(data, this.m[this.a:this.b])
-	this.a, this.a = 0, 0
-	if this.o != nil {
-		return i, this.o
-	}
-	n, this.o = this.r.Read(data[i:])
-	return n + i, this.o
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if this.a == this.b {
+		if e := this.fill(1); e != nil {
+			return 0, e
+		}
+	}
+	n := copy(data, this.m[this.a:this.b])
+	this.a += n
+	return n, nil
+}
+
+// 返回已读取的字节数，即下一个字节在输入中的偏移
+func (this *Iterator) InputOffset() int64 {
+	return this.p + int64(this.a)
 }
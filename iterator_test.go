@@ -0,0 +1,81 @@
+package encoding
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestIteratorPeek(t *testing.T) {
+	s := strings.Repeat("0123456789", 1000)
+	r := NewIterator(iotest.OneByteReader(strings.NewReader(s)))
+	b, e := r.Peek(10)
+	if e != nil || string(b) != s[:10] {
+		t.Fatalf("Peek(10) = %q, %v", b, e)
+	}
+	b, e = r.Peek(len(s))
+	if e != bufio.ErrBufferFull || len(b) != 4096 || string(b) != s[:4096] {
+		t.Fatalf("Peek(%d) = %d bytes, %v", len(s), len(b), e)
+	}
+	if _, e = r.Peek(-1); e != ErrNegativeCount {
+		t.Fatalf("Peek(-1): %v", e)
+	}
+	// 缓冲区不因Peek而增长
+	if len(r.m) != 4096 {
+		t.Fatalf("buffer grew to %d", len(r.m))
+	}
+	if _, e = r.Discard(4000); e != nil {
+		t.Fatal(e)
+	}
+	b, e = r.Peek(4096)
+	if e != nil || string(b) != s[4000:8096] {
+		t.Fatalf("Peek(4096) after Discard: %d bytes, %v", len(b), e)
+	}
+	if r.InputOffset() != 4000 {
+		t.Fatalf("offset %d", r.InputOffset())
+	}
+	u, e := io.ReadAll(r)
+	if e != nil || string(u) != s[4000:] {
+		t.Fatalf("ReadAll: %d bytes, %v", len(u), e)
+	}
+	b, e = r.Peek(1)
+	if e != io.EOF || len(b) != 0 {
+		t.Fatalf("Peek at end = %q, %v", b, e)
+	}
+}
+
+func TestIteratorUnreadByte(t *testing.T) {
+	r := NewIterator(bytes.NewReader([]byte("abc")))
+	if r.UnreadByte() != ErrUnreadByte {
+		t.Fatal("UnreadByte before any read")
+	}
+	c, _ := r.ReadByte()
+	if e := r.UnreadByte(); e != nil || c != 'a' {
+		t.Fatalf("%c, %v", c, e)
+	}
+	b, e := r.Peek(3)
+	if e != nil || string(b) != "abc" {
+		t.Fatalf("Peek(3) = %q, %v", b, e)
+	}
+}
+
+func TestIteratorReadN(t *testing.T) {
+	r := NewIterator(strings.NewReader("abcdef"))
+	b, e := r.ReadN(4)
+	if e != nil || string(b) != "abcd" {
+		t.Fatalf("ReadN(4) = %q, %v", b, e)
+	}
+	b, e = r.ReadN(1 << 20)
+	if e != io.ErrUnexpectedEOF || string(b) != "ef" {
+		t.Fatalf("ReadN(1<<20) = %q, %v", b, e)
+	}
+	if _, e = r.ReadN(1); e != io.EOF {
+		t.Fatalf("ReadN at end: %v", e)
+	}
+	if e = r.Skip(1); e != io.EOF {
+		t.Fatalf("Skip at end: %v", e)
+	}
+}
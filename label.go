@@ -0,0 +1,207 @@
+package encoding
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 表示一个标签
+type Label struct {
+	N int // 字段在其所在结构体中的索引；提升的字段所在的是被压平的内层结构体，此时N不是外层结构体的字段索引
+	V []string
+	I []int // 自最外层结构体起的索引序列，提升的字段长度大于1；预设的标签未设置时视为[]int{N}
+
+	OmitEmpty bool    // omitempty：编码时零值不编码，解码时缺失则置零
+	OmitZero  bool    // omitzero：编码时按IsZero方法或零值判断是否不编码
+	Required  bool    // required：解码时缺失该键返回MissingFieldError
+	Quoted    bool    // string：数值和布尔值编码为字符串，解码时亦接受字符串
+	Default   *string // default=VALUE：解码时缺失该键则按VALUE填充，未设置时为nil
+}
+
+// 字段名称
+func (this *Label) Name() string {
+	return this.V[0]
+}
+
+// 是否具有某属性
+func (this *Label) Has(s string) bool {
+	for i := 1; i < len(this.V); i++ {
+		if this.V[i] == s {
+			return true
+		}
+	}
+	return false
+}
+
+// 由属性解析各选项，default的值不能包含逗号
+func (this *Label) parse() {
+	for i := 1; i < len(this.V); i++ {
+		switch s := this.V[i]; {
+		case s == "omitempty":
+			this.OmitEmpty = true
+		case s == "omitzero":
+			this.OmitZero = true
+		case s == "required":
+			this.Required = true
+		case s == "string":
+			this.Quoted = true
+		case strings.HasPrefix(s, "default="):
+			s = s[len("default="):]
+			this.Default = &s
+		}
+	}
+}
+
+// 判断字段是否为收集未知键的remain字段，其类型须为键为字符串的map
+func remain(l *Label, t reflect.Type) bool {
+	return l.Has("remain") && t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
+}
+
+// 获取某结构体类型的标签信息
+//
+// 未设置名称的匿名结构体字段及具有inline属性的结构体字段会被压平，其字段提升到外层，
+// 同名字段按encoding/json的规则处理：层级浅的优先，同层级时设置了名称的优先，仍无法区分时均忽略
+func (this *Translator) GetLabel(x reflect.Type) []Label {
+	if p, ok := this.Tag[x]; ok {
+		return preset(p)
+	}
+	if p, ok := this.store().label.Load(x); ok {
+		return p.([]Label)
+	}
+	type (
+		field struct {
+			Label
+			depth int  // 所在的压平层级
+			named bool // 名称是否由标签设置
+		}
+		embed struct {
+			t reflect.Type
+			i []int
+		}
+	)
+	var (
+		all  []field
+		next = []embed{{x, nil}}
+		done = map[reflect.Type]bool{}
+	)
+	for depth := 0; len(next) > 0; depth++ {
+		cur, level := next, []embed(nil)
+		for _, s := range cur {
+			if done[s.t] {
+				continue
+			}
+			for i, l := 0, s.t.NumField(); i < l; i++ {
+				f := s.t.Field(i)
+				tag := f.Tag.Get(this.Name)
+				if tag == "-" {
+					continue
+				}
+				y := make([]string, 0, 0)
+				for _, x := range strings.Split(tag, ",") {
+					y = append(y, strings.TrimSpace(x))
+				}
+				k := append(append(make([]int, 0, len(s.i)+1), s.i...), i)
+				t := f.Type
+				if t.Kind() == reflect.Ptr && t.Name() == "" {
+					t = t.Elem()
+				}
+				u := Label{V: y}
+				if t.Kind() == reflect.Struct && (f.Anonymous && y[0] == "" || u.Has("inline")) {
+					if f.PkgPath != "" && f.Type.Kind() == reflect.Ptr {
+						continue
+					}
+					level = append(level, embed{t, k})
+					continue
+				}
+				if f.PkgPath != "" {
+					continue
+				}
+				named := y[0] != ""
+				if !named {
+					y[0] = f.Name
+				}
+				u = Label{N: i, V: y, I: k}
+				u.parse()
+				all = append(all, field{u, depth, named})
+			}
+		}
+		for _, s := range cur {
+			done[s.t] = true
+		}
+		next = level
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Name() != all[j].Name() {
+			return all[i].Name() < all[j].Name()
+		}
+		if all[i].depth != all[j].depth {
+			return all[i].depth < all[j].depth
+		}
+		return all[i].named && !all[j].named
+	})
+	p := make([]Label, 0, len(all))
+	for i, j := 0, 0; i < len(all); i = j {
+		for j = i + 1; j < len(all) && all[j].Name() == all[i].Name(); j++ {
+		}
+		if j-i > 1 && all[i+1].depth == all[i].depth && all[i+1].named == all[i].named {
+			continue
+		}
+		p = append(p, all[i].Label)
+	}
+	sort.Slice(p, func(i, j int) bool {
+		a, b := p[i].I, p[j].I
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	q, _ := this.store().label.LoadOrStore(x, p)
+	return q.([]Label)
+}
+
+// 为预设的标签中未设置I的补上[]int{N}，Tag只读，因而在副本上修改
+func preset(p []Label) []Label {
+	for i := range p {
+		if p[i].I == nil {
+			q := append([]Label(nil), p...)
+			for j := i; j < len(q); j++ {
+				if q[j].I == nil {
+					q[j].I = []int{q[j].N}
+				}
+			}
+			return q
+		}
+	}
+	return p
+}
+
+// 按索引序列获取字段，途经nil指针时返回false
+func fieldOf(x reflect.Value, i []int) (reflect.Value, bool) {
+	for k, n := range i {
+		if k > 0 && x.Kind() == reflect.Ptr {
+			if x.IsNil() {
+				return reflect.Value{}, false
+			}
+			x = x.Elem()
+		}
+		x = x.Field(n)
+	}
+	return x, true
+}
+
+// 按索引序列获取字段，途经nil指针时为其分配内存
+func fieldAlloc(x reflect.Value, i []int) reflect.Value {
+	for k, n := range i {
+		if k > 0 && x.Kind() == reflect.Ptr {
+			if x.IsNil() {
+				x.Set(reflect.New(x.Type().Elem()))
+			}
+			x = x.Elem()
+		}
+		x = x.Field(n)
+	}
+	return x
+}
@@ -0,0 +1,153 @@
+package encoding
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type (
+	base struct {
+		ID   int `test:"id"`
+		Name string
+	}
+	Extra struct {
+		Note string `test:"note"`
+		inner
+	}
+	inner struct {
+		Name string // 层级更深，被base的Name遮蔽
+	}
+	sub struct {
+		Tail string `test:"tail"`
+	}
+	flat struct {
+		base
+		*Extra
+		Sub  sub  `test:",inline"`
+		Kept base `test:"kept"`
+	}
+	// 同层级同名且均未设置名称的字段都被忽略
+	clash struct {
+		left
+		right
+	}
+	left  struct{ X, L int }
+	right struct{ X, R int }
+)
+
+func TestFlatten(t *testing.T) {
+	x := NewTranslator("test", nil)
+	var names []string
+	for _, l := range x.GetLabel(reflect.TypeOf(flat{})) {
+		names = append(names, l.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"id", "Name", "note", "tail", "kept"}) {
+		t.Fatalf("labels = %v", names)
+	}
+	v := flat{base: base{1, "n"}, Extra: &Extra{"e", inner{"i"}}, Sub: sub{"s"}, Kept: base{4, "k"}}
+	d, e := x.Encode(reflect.ValueOf(v))
+	want := []Attr{{"id", int64(1)}, {"Name", "n"}, {"note", "e"}, {"tail", "s"}, {"kept", []Attr{{"id", int64(4)}, {"Name", "k"}}}}
+	if e != nil || !reflect.DeepEqual(d, want) {
+		t.Fatalf("Encode = %#v, %v", d, e)
+	}
+	var y flat
+	e = x.Decode(reflect.ValueOf(&y).Elem(), map[string]interface{}{"id": int64(5), "Name": "a", "note": "m"})
+	if e != nil || y.ID != 5 || y.Name != "a" || y.Extra == nil || y.Note != "m" || y.inner.Name != "" {
+		t.Fatalf("Decode = %+v, %v", y, e)
+	}
+	for _, l := range x.GetLabel(reflect.TypeOf(clash{})) {
+		if l.Name() == "X" {
+			t.Fatal("ambiguous field X not dropped")
+		}
+	}
+}
+
+type open struct {
+	A    int                    `test:"a"`
+	Rest map[string]interface{} `test:",remain"`
+}
+
+func TestRemain(t *testing.T) {
+	x := NewTranslator("test", nil)
+	var y open
+	e := x.Decode(reflect.ValueOf(&y).Elem(), map[string]interface{}{"a": int64(1), "b": "x", "c": int64(2)})
+	if e != nil || y.A != 1 || !reflect.DeepEqual(y.Rest, map[string]interface{}{"b": "x", "c": int64(2)}) {
+		t.Fatalf("Decode = %+v, %v", y, e)
+	}
+	x.Sorted = true
+	d, e := x.Encode(reflect.ValueOf(y))
+	if e != nil || !reflect.DeepEqual(d, []Attr{{"a", int64(1)}, {"b", "x"}, {"c", int64(2)}}) {
+		t.Fatalf("Encode = %#v, %v", d, e)
+	}
+	x.Strict = true
+	if e = x.Decode(reflect.ValueOf(&y).Elem(), map[string]interface{}{"z": int64(1)}); e != nil {
+		t.Fatalf("strict Decode = %v", e)
+	}
+}
+
+// 值为7时视为零值
+type seven int
+
+func (this seven) IsZero() bool {
+	return this == 7
+}
+
+type options struct {
+	Port  int       `test:"port,string"`
+	Ratio float64   `test:"ratio,string,omitempty"`
+	Ok    *bool     `test:"ok,string,omitempty"`
+	Name  string    `test:"name,required"`
+	Level int       `test:"level,default=5"`
+	Tag   string    `test:"tag,default=abc"`
+	T     time.Time `test:"t,omitzero"`
+	S     seven     `test:"s,omitzero"`
+}
+
+func TestOptions(t *testing.T) {
+	x := NewTranslator("test", nil)
+	b := true
+	for _, c := range []struct {
+		in   options
+		want []Attr
+	}{
+		{options{Port: 6881, Ratio: 1.5, Ok: &b, Name: "n", Level: 1, Tag: "t", S: 1}, []Attr{
+			{"port", "6881"}, {"ratio", "1.5"}, {"ok", "true"}, {"name", "n"}, {"level", int64(1)}, {"tag", "t"}, {"s", int64(1)},
+		}},
+		{options{S: 7}, []Attr{{"port", "0"}, {"name", ""}, {"level", int64(0)}, {"tag", ""}}},
+	} {
+		d, e := x.Encode(reflect.ValueOf(c.in))
+		if e != nil || !reflect.DeepEqual(d, c.want) {
+			t.Errorf("Encode(%+v) = %#v, %v", c.in, d, e)
+		}
+	}
+	for _, c := range []struct {
+		in   map[string]interface{}
+		want options
+		err  error
+	}{
+		{map[string]interface{}{"name": "n", "port": "6881", "ok": "false"}, options{Port: 6881, Ok: new(bool), Name: "n", Level: 5, Tag: "abc"}, nil},
+		{map[string]interface{}{"name": "n", "port": int64(1), "ratio": "0.5", "level": int64(2)}, options{Port: 1, Ratio: 0.5, Name: "n", Level: 2, Tag: "abc"}, nil},
+		{map[string]interface{}{"port": "1"}, options{}, &MissingFieldError{Key: "name"}},
+		{map[string]interface{}{"name": "n", "port": "x"}, options{}, UnmatchedType},
+	} {
+		var y options
+		e := x.Decode(reflect.ValueOf(&y).Elem(), c.in)
+		switch w := c.err.(type) {
+		case nil:
+			if e != nil || !reflect.DeepEqual(y, c.want) {
+				t.Errorf("Decode(%v) = %+v, %v", c.in, y, e)
+			}
+		case *MissingFieldError:
+			var m *MissingFieldError
+			if !errors.As(e, &m) || m.Key != w.Key {
+				t.Errorf("Decode(%v) = %v, want missing %s", c.in, e, w.Key)
+			}
+		default:
+			if !errors.Is(e, w) {
+				t.Errorf("Decode(%v) = %v, want %v", c.in, e, w)
+			}
+		}
+	}
+}
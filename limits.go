@@ -0,0 +1,73 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// 字符串超出了Limits.MaxString
+	StringTooLong = errors.New("string too long")
+	// 列表或字典的成员数超出了Limits.MaxLength
+	TooManyItems = errors.New("too many items")
+	// 嵌套深度超出了Limits.MaxDepth
+	NestingTooDeep = errors.New("nesting too deep")
+	// 一个值的输入字节数超出了Limits.MaxBytes
+	ValueTooLarge = errors.New("value too large")
+)
+
+// 解码不可信输入时的资源限制，各项为0时不限制
+type Limits struct {
+	MaxString int   // 字符串和字节串的最大长度
+	MaxLength int   // 列表和字典的最大成员数
+	MaxDepth  int   // 列表、字典和对象的最大嵌套深度
+	MaxBytes  int64 // 一个顶层值的最大输入字节数
+}
+
+// 检查字符串长度
+func (this *Limits) String(n int) error {
+	if this.MaxString > 0 && n > this.MaxString {
+		return StringTooLong
+	}
+	return nil
+}
+
+// 检查列表或字典的成员数
+func (this *Limits) Length(n int) error {
+	if this.MaxLength > 0 && n > this.MaxLength {
+		return TooManyItems
+	}
+	return nil
+}
+
+// 检查嵌套深度
+func (this *Limits) Depth(n int) error {
+	if this.MaxDepth > 0 && n > this.MaxDepth {
+		return NestingTooDeep
+	}
+	return nil
+}
+
+// 检查一个值已经或将要读取的字节数
+func (this *Limits) Bytes(n int64) error {
+	if this.MaxBytes > 0 && n > this.MaxBytes {
+		return ValueTooLarge
+	}
+	return nil
+}
+
+// 解码时超出了资源限制，可用errors.Is与具体的限制错误比较
+type LimitError struct {
+	Offset int64 // 超出限制时在输入中的偏移
+	Err    error // StringTooLong、TooManyItems、NestingTooDeep或ValueTooLarge
+}
+
+// 实现error接口
+func (this *LimitError) Error() string {
+	return fmt.Sprintf("%v at offset %d", this.Err, this.Offset)
+}
+
+// 返回具体的限制错误
+func (this *LimitError) Unwrap() error {
+	return this.Err
+}
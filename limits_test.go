@@ -0,0 +1,28 @@
+package encoding
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	l := Limits{MaxString: 2, MaxLength: 2, MaxDepth: 2, MaxBytes: 2}
+	var zero Limits
+	for _, c := range []struct {
+		f    func(*Limits, int) error
+		want error
+	}{
+		{func(l *Limits, n int) error { return l.String(n) }, StringTooLong},
+		{func(l *Limits, n int) error { return l.Length(n) }, TooManyItems},
+		{func(l *Limits, n int) error { return l.Depth(n) }, NestingTooDeep},
+		{func(l *Limits, n int) error { return l.Bytes(int64(n)) }, ValueTooLarge},
+	} {
+		if c.f(&l, 2) != nil || c.f(&l, 3) != c.want || c.f(&zero, 1<<30) != nil {
+			t.Errorf("%v: wrong limit check", c.want)
+		}
+	}
+	e := error(&LimitError{Offset: 7, Err: TooManyItems})
+	if !errors.Is(e, TooManyItems) || e.Error() != "too many items at offset 7" {
+		t.Errorf("got %v", e)
+	}
+}
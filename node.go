@@ -0,0 +1,352 @@
+package encoding
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 文档节点的种类
+type NodeKind uint8
+
+const (
+	NullNode      NodeKind = iota // 空值
+	BoolNode                      // 布尔值
+	IntNode                       // 有符号整数
+	UintNode                      // 无符号整数
+	FloatNode                     // 浮点数
+	StringNode                    // 字符串
+	BytesNode                     // 字节串
+	ListNode                      // 列表
+	DictNode                      // 字典
+	ObjectNode                    // 具有类名的对象
+	UndefinedNode                 // 未定义值
+	RawNode                       // 格式特有的值，如AMF的日期和XML
+)
+
+var nodeKinds = [...]string{"null", "bool", "int", "uint", "float", "string", "bytes", "list", "dict", "object", "undefined", "raw"}
+
+// 种类的名称
+func (this NodeKind) String() string {
+	if int(this) < len(nodeKinds) {
+		return nodeKinds[this]
+	}
+	return "NodeKind(" + strconv.Itoa(int(this)) + ")"
+}
+
+// 字典或对象的一个键值对
+type Field struct {
+	Key   string
+	Value *Node
+}
+
+// 带类型的文档树，各编码格式的解码器直接读取为Node，编码器直接写出Node；Translator也可直接解码Node
+type Node struct {
+	Kind  NodeKind
+	Bool  bool
+	Int   int64
+	Uint  uint64
+	Float float64
+	Str   string      // 字符串的值，或对象的类名
+	Bytes []byte      // 字节串的值
+	List  []*Node     // 列表的成员
+	Dict  []Field     // 字典或对象的键值对，按出现顺序
+	Raw   interface{} // 格式特有的值
+
+	Offset int64 // 解码得到的节点在输入中的起始偏移，被引用的值为其首次出现的位置
+}
+
+var nodeType = reflect.TypeOf(Node{})
+
+// 获取字典或对象中某键的值，不存在时返回nil
+func (this *Node) Key(k string) *Node {
+	if this == nil || this.Kind != DictNode && this.Kind != ObjectNode {
+		return nil
+	}
+	for _, f := range this.Dict {
+		if f.Key == k {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+// 获取列表的第i个成员，不存在时返回nil
+func (this *Node) Index(i int) *Node {
+	if this == nil || this.Kind != ListNode || i < 0 || i >= len(this.List) {
+		return nil
+	}
+	return this.List[i]
+}
+
+// 列表、字典、对象的成员数，字符串和字节串的长度
+func (this *Node) Len() int {
+	if this == nil {
+		return 0
+	}
+	switch this.Kind {
+	case StringNode:
+		return len(this.Str)
+	case BytesNode:
+		return len(this.Bytes)
+	case ListNode:
+		return len(this.List)
+	case DictNode, ObjectNode:
+		return len(this.Dict)
+	}
+	return 0
+}
+
+// 按形如info.files[0].path的路径获取节点，不存在或路径格式错误时返回nil，空路径返回节点自身
+func (this *Node) Get(path string) *Node {
+	if path == "" {
+		return this
+	}
+	x := this
+	for _, p := range strings.Split(path, ".") {
+		k := p
+		if i := strings.IndexByte(p, '['); i >= 0 {
+			k, p = p[:i], p[i:]
+		} else {
+			p = ""
+		}
+		if k == "" && p == "" {
+			return nil
+		}
+		if k != "" {
+			x = x.Key(k)
+		}
+		for p != "" {
+			j := strings.IndexByte(p, ']')
+			if p[0] != '[' || j < 2 || strings.Trim(p[1:j], "0123456789") != "" {
+				return nil
+			}
+			i, e := strconv.Atoi(p[1:j])
+			if e != nil {
+				return nil
+			}
+			x, p = x.Index(i), p[j+1:]
+		}
+		if x == nil {
+			return nil
+		}
+	}
+	return x
+}
+
+// 由中间数据构建节点，class为表示类名的键
+//
+// 结构体编码成的[]Attr在class非空时成为对象，其余的字典含有class键时成为对象
+func NewNode(d interface{}, class string) *Node {
+	switch u := d.(type) {
+	case nil:
+		return &Node{Kind: NullNode}
+	case Undefined:
+		return &Node{Kind: UndefinedNode}
+	case *Node:
+		if u == nil {
+			return &Node{Kind: NullNode}
+		}
+		return u
+	case Node:
+		return &u
+	case bool:
+		return &Node{Kind: BoolNode, Bool: u}
+	case int64:
+		return &Node{Kind: IntNode, Int: u}
+	case uint64:
+		return &Node{Kind: UintNode, Uint: u}
+	case float64:
+		return &Node{Kind: FloatNode, Float: u}
+	case string:
+		return &Node{Kind: StringNode, Str: u}
+	case []byte:
+		return &Node{Kind: BytesNode, Bytes: u}
+	case []interface{}:
+		x := &Node{Kind: ListNode, List: make([]*Node, 0, len(u))}
+		for _, v := range u {
+			x.List = append(x.List, NewNode(v, class))
+		}
+		return x
+	case map[string]interface{}:
+		k := make([]string, 0, len(u))
+		for K := range u {
+			k = append(k, K)
+		}
+		sort.Strings(k)
+		x := &Node{Kind: DictNode, Dict: make([]Field, 0, len(k))}
+		for _, K := range k {
+			x.field(K, NewNode(u[K], class), class)
+		}
+		return x
+	case []Item:
+		x := &Node{Kind: DictNode, Dict: make([]Field, 0, len(u))}
+		for _, v := range u {
+			K, ok := v.K.(string)
+			if !ok {
+				K = fmt.Sprint(v.K)
+			}
+			x.field(K, NewNode(v.V, class), class)
+		}
+		return x
+	case []Attr:
+		x := &Node{Kind: DictNode, Dict: make([]Field, 0, len(u))}
+		if class != "" {
+			x.Kind = ObjectNode
+		}
+		for _, v := range u {
+			x.field(v.K, NewNode(v.V, class), class)
+		}
+		return x
+	}
+	return &Node{Kind: RawNode, Raw: d}
+}
+
+// 添加键值对，表示类名的键使字典成为对象
+func (this *Node) field(k string, v *Node, class string) {
+	if class != "" && k == class && v.Kind == StringNode {
+		this.Kind, this.Str = ObjectNode, v.Str
+		return
+	}
+	this.Dict = append(this.Dict, Field{k, v})
+}
+
+// 将节点转换为一层中间数据，成员仍为*Node，供编码器逐层写出
+//
+// 列表为[]interface{}，字典为[]Item，对象为[]Attr，class非空时其首项为类名
+func (this *Node) Value(class string) interface{} {
+	if this == nil {
+		return nil
+	}
+	switch this.Kind {
+	case BoolNode:
+		return this.Bool
+	case IntNode:
+		return this.Int
+	case UintNode:
+		return this.Uint
+	case FloatNode:
+		return this.Float
+	case StringNode:
+		return this.Str
+	case BytesNode:
+		return this.Bytes
+	case ListNode:
+		u := make([]interface{}, 0, len(this.List))
+		for _, v := range this.List {
+			u = append(u, v)
+		}
+		return u
+	case DictNode:
+		u := make([]Item, 0, len(this.Dict))
+		for _, f := range this.Dict {
+			u = append(u, Item{f.Key, f.Value})
+		}
+		return u
+	case ObjectNode:
+		u := make([]Attr, 0, len(this.Dict)+1)
+		if class != "" {
+			u = append(u, Attr{class, this.Str})
+		}
+		for _, f := range this.Dict {
+			u = append(u, Attr{f.Key, f.Value})
+		}
+		return u
+	case UndefinedNode:
+		return Undefined{}
+	case RawNode:
+		return this.Raw
+	}
+	return nil
+}
+
+// 将节点完全转换为中间数据，形式同Value
+func (this *Node) Data(class string) interface{} {
+	if this == nil {
+		return nil
+	}
+	u := this.Value(class)
+	switch this.Kind {
+	case ListNode:
+		l := u.([]interface{})
+		for i, v := range l {
+			l[i] = v.(*Node).Data(class)
+		}
+	case DictNode:
+		d := u.([]Item)
+		for i, v := range d {
+			d[i].V = v.V.(*Node).Data(class)
+		}
+	case ObjectNode:
+		d := u.([]Attr)
+		for i, v := range d {
+			if n, ok := v.V.(*Node); ok {
+				d[i].V = n.Data(class)
+			}
+		}
+	}
+	return u
+}
+
+// 将节点转换为供解码使用的一层中间数据，字典和对象为map[string]interface{}，对象含有class键
+func (this *Node) shallow(class string) interface{} {
+	if this != nil && (this.Kind == DictNode || this.Kind == ObjectNode) {
+		u := make(map[string]interface{}, len(this.Dict)+1)
+		if this.Kind == ObjectNode && class != "" {
+			u[class] = this.Str
+		}
+		for _, f := range this.Dict {
+			u[f.Key] = f.Value
+		}
+		return u
+	}
+	return this.Value(class)
+}
+
+// 将含有*Node的中间数据完全转换，用于需要整个值的解码方案
+//
+// 同一节点（如AMF的引用）只转换一次，各处共享其结果，避免共享的节点被反复展开
+func (this *decodeState) whole(d interface{}) interface{} {
+	switch u := d.(type) {
+	case *Node:
+		if r, ok := this.done[u]; ok {
+			return r
+		}
+		r := this.whole(u.shallow(this.t.Class))
+		if this.done == nil {
+			this.done = make(map[*Node]interface{})
+		}
+		this.done[u] = r
+		return r
+	case []interface{}:
+		r := make([]interface{}, len(u))
+		for i, v := range u {
+			r[i] = this.whole(v)
+		}
+		return r
+	case map[string]interface{}:
+		r := make(map[string]interface{}, len(u))
+		for k, v := range u {
+			r[k] = this.whole(v)
+		}
+		return r
+	}
+	return d
+}
+
+// 编码为*Node，由各编码器直接写出
+func nodeEncoder(s *encodeState, x reflect.Value) (interface{}, error) {
+	if x.CanAddr() {
+		return x.Addr().Interface(), nil
+	}
+	u := x.Interface().(Node)
+	return &u, nil
+}
+
+// 由中间数据构建节点，解码器产生的*Node在Decode中直接赋值
+func nodeDecoder(s *decodeState, x reflect.Value, d interface{}) error {
+	x.Set(reflect.ValueOf(*NewNode(d, s.t.Class)))
+	return nil
+}
@@ -0,0 +1,112 @@
+package encoding
+
+import (
+	"reflect"
+	"testing"
+)
+
+func torrentNode() *Node {
+	return NewNode(map[string]interface{}{
+		"announce": "http://tracker",
+		"info": map[string]interface{}{
+			"name": "dir",
+			"files": []interface{}{
+				map[string]interface{}{"length": int64(1), "path": []interface{}{"a", "b"}},
+				map[string]interface{}{"length": int64(2), "path": []interface{}{"c"}},
+			},
+		},
+		"nested": []interface{}{[]interface{}{int64(7)}},
+	}, "")
+}
+
+func TestNodeGet(t *testing.T) {
+	n := torrentNode()
+	for _, c := range []struct {
+		path string
+		want interface{} // nil表示不存在
+	}{
+		{"announce", "http://tracker"},
+		{"info.name", "dir"},
+		{"info.files[0].length", int64(1)},
+		{"info.files[1].path[0]", "c"},
+		{"info.files[0].path[1]", "b"},
+		{"nested[0][0]", int64(7)},
+		// 越界的索引
+		{"info.files[2].length", nil},
+		{"info.files[0].path[2]", nil},
+		{"nested[0][1]", nil},
+		// 不存在的键，或对非列表使用索引、对非字典使用键
+		{"info.missing", nil},
+		{"announce[0]", nil},
+		{"info.files.length", nil},
+		// 格式错误的路径
+		{"info.files[", nil},
+		{"info.files[]", nil},
+		{"info.files[x]", nil},
+		{"info.files[-1]", nil},
+		{"info.files[+0].length", nil},
+		{"info.files[0]x", nil},
+		{"info.files]0[", nil},
+		{"info..name", nil},
+		{"info.", nil},
+		{".info", nil},
+	} {
+		x := n.Get(c.path)
+		switch {
+		case c.want == nil && x != nil:
+			t.Errorf("Get(%q) = %v, want nil", c.path, x.Data(""))
+		case c.want != nil && (x == nil || !reflect.DeepEqual(x.Data(""), c.want)):
+			t.Errorf("Get(%q) = %v, want %v", c.path, x, c.want)
+		}
+	}
+	if n.Get("") != n {
+		t.Error("Get(\"\") is not the node itself")
+	}
+	if p := n.Get("info.files[0].path"); p.Len() != 2 || p.Kind != ListNode {
+		t.Errorf("Get(info.files[0].path) = %v", p)
+	}
+	var z *Node
+	if z.Get("a[0]") != nil {
+		t.Error("Get on nil node")
+	}
+}
+
+type object struct {
+	Class string `test:"$"`
+	A     int64  `test:"a"`
+}
+
+func TestNodeObject(t *testing.T) {
+	x := NewTranslator("test", nil)
+	x.Class = "$"
+	d, e := x.Encode(reflect.ValueOf(object{"T", 1}))
+	if e != nil {
+		t.Fatal(e)
+	}
+	n := NewNode(d, "$")
+	if n.Kind != ObjectNode || n.Str != "T" || n.Key("a").Int != 1 || n.Len() != 1 {
+		t.Fatalf("NewNode = %+v", n)
+	}
+	if u := NewNode(map[string]interface{}{"a": int64(1)}, "$"); u.Kind != DictNode {
+		t.Fatalf("dict without class key became %v", u.Kind)
+	}
+	if !reflect.DeepEqual(n.Data("$"), []Attr{{"$", "T"}, {"a", int64(1)}}) {
+		t.Fatalf("Data = %#v", n.Data("$"))
+	}
+	var y object
+	if e = x.Decode(reflect.ValueOf(&y).Elem(), n); e != nil || y != (object{"T", 1}) {
+		t.Fatalf("Decode(node) = %+v, %v", y, e)
+	}
+	var m map[string]interface{}
+	if e = x.Decode(reflect.ValueOf(&m).Elem(), n); e != nil || !reflect.DeepEqual(m, map[string]interface{}{"$": "T", "a": int64(1)}) {
+		t.Fatalf("Decode(node) to map = %#v, %v", m, e)
+	}
+	var i interface{}
+	if e = x.Decode(reflect.ValueOf(&i).Elem(), n); e != nil || !reflect.DeepEqual(i, m) {
+		t.Fatalf("Decode(node) to interface = %#v, %v", i, e)
+	}
+	var z Node
+	if e = x.Decode(reflect.ValueOf(&z).Elem(), n); e != nil || z.Str != "T" {
+		t.Fatalf("Decode(node) to Node = %+v, %v", z, e)
+	}
+}
@@ -0,0 +1,116 @@
+package encoding
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// 用于识别正在访问的指针、map和slice
+type visit struct {
+	p uintptr
+	t reflect.Type
+	n int
+}
+
+// 路径的一步，依次尝试字段名、map的键和索引
+type step struct {
+	f string
+	k reflect.Value
+	i int
+}
+
+// 由路径的各步生成形如Info.Files[3].Length的字符串
+func trace(path []step) string {
+	w := bytes.NewBuffer(nil)
+	for _, p := range path {
+		switch {
+		case p.f != "":
+			if w.Len() != 0 {
+				w.WriteByte('.')
+			}
+			w.WriteString(p.f)
+		case p.k.IsValid():
+			fmt.Fprintf(w, "[%v]", p.k.Interface())
+		default:
+			fmt.Fprintf(w, "[%d]", p.i)
+		}
+	}
+	return w.String()
+}
+
+// 编码过程的状态
+type encodeState struct {
+	t     *Translator
+	seen  map[visit]int // 正在访问的值及其所在的层级
+	path  []step        // 当前值的路径
+	level int           // 当前所在的容器层级
+}
+
+// 标记开始访问某值，如该值已在访问中，返回其所在层级和false
+func (this *encodeState) enter(k visit) (int, bool) {
+	if r, ok := this.seen[k]; ok {
+		return r, false
+	}
+	this.seen[k] = this.level
+	return this.level, true
+}
+
+// 处理值的循环引用，r为被引用容器所在的层级
+func (this *encodeState) cycle(r int, y reflect.Type) (interface{}, error) {
+	if this.t.Refer && r < this.level {
+		return Reference{r}, nil
+	}
+	return nil, &CycleError{trace(this.path), y}
+}
+
+func (this *encodeState) push(p step) {
+	this.path = append(this.path, p)
+}
+
+func (this *encodeState) pop() {
+	this.path = this.path[:len(this.path)-1]
+}
+
+// 解码过程的状态
+type decodeState struct {
+	t      *Translator
+	path   []step                 // 当前值的路径
+	offset int64                  // 当前节点在输入中的偏移，未知时为-1
+	done   map[*Node]interface{}  // 已完全转换的节点
+	memo   map[memo]reflect.Value // 已解码到某类型的节点及其结果
+}
+
+// 节点及其解码到的类型
+type memo struct {
+	n *Node
+	t reflect.Type
+}
+
+func (this *decodeState) push(p step) {
+	this.path = append(this.path, p)
+}
+
+func (this *decodeState) pop() {
+	this.path = this.path[:len(this.path)-1]
+}
+
+// 生成类型不匹配的错误
+func (this *decodeState) unmatched(t reflect.Type, d interface{}, e error) error {
+	return &UnmatchedError{Path: trace(this.path), Offset: this.offset, Expected: t.String(), Found: describe(d), Err: e}
+}
+
+// 描述中间数据的类型
+func describe(d interface{}) string {
+	switch d.(type) {
+	case nil:
+		return "null"
+	case Undefined:
+		return "undefined"
+	case []interface{}:
+		return "list"
+	case []Item, []Attr, map[string]interface{}:
+		return "dict"
+	}
+	return reflect.TypeOf(d).String()
+}
@@ -0,0 +1,70 @@
+package encoding
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// 可将输入解码为节点的解码器
+type Decoder interface {
+	DecodeNode() (*Node, error)
+}
+
+// 可将节点编码后输出的编码器
+type Encoder interface {
+	EncodeNode(*Node) error
+}
+
+// 从src解码一个值，再用dst编码输出，用于不同格式之间的转换
+func Transcode(dst Encoder, src Decoder) error {
+	n, e := src.DecodeNode()
+	if e != nil {
+		return e
+	}
+	return dst.EncodeNode(n)
+}
+
+// 目标格式无法表示某节点
+type LossError struct {
+	Path   string // 节点的路径
+	Format string // 目标格式
+	Node   *Node  // 无法表示的节点
+}
+
+// 实现error接口
+func (this *LossError) Error() string {
+	k := this.Node.Kind.String()
+	if this.Node.Kind == RawNode {
+		k += " " + reflect.TypeOf(this.Node.Raw).String()
+	}
+	return fmt.Sprintf("%s cannot represent %s%s", this.Format, k, at(this.Path))
+}
+
+// 深度优先检查各节点，对第一个不满足ok的节点返回LossError
+func (this *Node) Check(format string, ok func(*Node) bool) error {
+	return this.check(format, ok, nil)
+}
+
+func (this *Node) check(format string, ok func(*Node) bool, path []step) error {
+	if this == nil {
+		this = &Node{Kind: NullNode}
+	}
+	if !ok(this) {
+		return &LossError{trace(path), format, this}
+	}
+	switch this.Kind {
+	case ListNode:
+		for i, v := range this.List {
+			if e := v.check(format, ok, append(path, step{i: i})); e != nil {
+				return e
+			}
+		}
+	case DictNode, ObjectNode:
+		for _, f := range this.Dict {
+			if e := f.Value.check(format, ok, append(path, step{f: f.Key})); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
@@ -2,8 +2,11 @@ package encoding
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
-	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
 )
 
 var (
@@ -13,6 +16,10 @@ var (
 	UnmatchedType = errors.New("unmatched type")
 	// 编码格式错误
 	SyntaxError = errors.New("syntax error")
+	// 解码时数值超出了目标类型的范围
+	NumberOverflow = errors.New("number overflow")
+	// 解码时数值无法被目标类型精确表示
+	PrecisionLoss = errors.New("precision loss")
 )
 
 // 表示映射的一个键值对
@@ -30,278 +37,233 @@ type Attr struct {
 // 用来表示一个“未定义值”
 type Undefined struct{}
 
-// 实现中间数据与具体类型编解码
+// 表示对外层容器的回溯引用，N为被引用容器在当前路径上的层级（0为最外层）
+type Reference struct {
+	N int
+}
+
+// 编码时遇到值的循环引用
+type CycleError struct {
+	Path string       // 出现循环引用的路径
+	Type reflect.Type // 出现循环引用的类型
+}
+
+// 实现error接口
+func (this *CycleError) Error() string {
+	return fmt.Sprintf("value cycle of %s at %s", this.Type, this.Path)
+}
+
+// 严格模式下解码遇到无对应字段的键
+type UnknownFieldError struct {
+	Path string       // 结构体的路径
+	Key  string       // 未知的键
+	Type reflect.Type // 被解码的结构体类型
+}
+
+// 实现error接口
+func (this *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q in %s%s", this.Key, this.Type, at(this.Path))
+}
+
+// 解码时缺失具有required属性的字段
+type MissingFieldError struct {
+	Path string       // 结构体的路径
+	Key  string       // 缺失的键
+	Type reflect.Type // 被解码的结构体类型
+}
+
+// 实现error接口
+func (this *MissingFieldError) Error() string {
+	return fmt.Sprintf("missing required field %q in %s%s", this.Key, this.Type, at(this.Path))
+}
+
+// 解码时中间数据与值的类型不匹配，可用errors.Is与UnmatchedType比较
+type UnmatchedError struct {
+	Path     string // 出错值的路径，如Info.Files[3].Length
+	Offset   int64  // 出错值在输入中的起始偏移，中间数据不是解码器产生的节点时为-1
+	Expected string // 期望的类型
+	Found    string // 实际遇到的中间数据类型
+	Err      error  // 具体的原因，默认为UnmatchedType
+}
+
+// 实现error接口
+func (this *UnmatchedError) Error() string {
+	s := fmt.Sprintf("%v%s: expected %s, found %s", this.Err, at(this.Path), this.Expected, this.Found)
+	if this.Offset >= 0 {
+		s += fmt.Sprintf(" (at offset %d)", this.Offset)
+	}
+	return s
+}
+
+// 返回具体的原因
+func (this *UnmatchedError) Unwrap() error {
+	return this.Err
+}
+
+// 总是可与UnmatchedType匹配
+func (this *UnmatchedError) Is(e error) bool {
+	return e == UnmatchedType
+}
+
+// 编码格式错误及其位置，可用errors.Is与SyntaxError比较
+type FormatError struct {
+	Offset int64  // 出错处在输入中的偏移
+	Msg    string // 错误描述
+}
+
+// 实现error接口
+func (this *FormatError) Error() string {
+	return fmt.Sprintf("syntax error at offset %d: %s", this.Offset, this.Msg)
+}
+
+// 返回SyntaxError
+func (this *FormatError) Unwrap() error {
+	return SyntaxError
+}
+
+// 格式化错误信息中的路径
+func at(path string) string {
+	if path == "" {
+		return ""
+	}
+	return " at " + path
+}
+
+// 实现该接口的类型可以自行编码为中间数据
+type Marshaler interface {
+	MarshalData() (interface{}, error)
+}
+
+// 实现该接口的类型可以自行解码中间数据
+type Unmarshaler interface {
+	UnmarshalData(interface{}) error
+}
+
+// 同标准库encoding.TextMarshaler，编码为字符串
+type textMarshaler interface {
+	MarshalText() ([]byte, error)
+}
+
+// 同标准库encoding.TextUnmarshaler，从字符串解码
+type textUnmarshaler interface {
+	UnmarshalText([]byte) error
+}
+
+var (
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*textMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*textUnmarshaler)(nil)).Elem()
+)
+
+// 实现中间数据与具体类型编解码，可被多个goroutine同时使用；零值可直接使用
 type Translator struct {
-	Name string
-	Tag  map[reflect.Type][]Label
-	Raw  map[reflect.Type]struct{}
+	Name    string
+	Raw     map[reflect.Type]struct{}
+	Tag     map[reflect.Type][]Label // 预设的标签信息，优先于结构体标签；只读，不再写入
+	Class   string                   // 表示类名的键，严格模式下不视为未知字段
+	Refer   bool                     // 为真时值的循环引用编码为Reference，否则返回CycleError
+	Strict  bool                     // 为真时解码遇到无对应字段的键返回UnknownFieldError
+	OnlyNil bool                     // 为真时omitempty只将nil的slice和map视为零值
+	Sorted  bool                     // 为真时结构体字段按键的字节序编码，否则按声明顺序；map总是按键排序
+	cache   unsafe.Pointer           // *cache，首次使用时创建
 }
 
-// 获取某结构体类型的标签信息
-func (this *Translator) GetLabel(x reflect.Type) []Label {
-	p := make([]Label, 0, 0)
-	for i, l := 0, x.NumField(); i < l; i++ {
-		f := x.Field(i)
-		if f.Name[0] < 'A' || f.Name[0] > 'Z' {
-			continue
-		}
-		tag := f.Tag.Get(string(this.Name))
-		if tag == "-" {
-			continue
-		}
-		if tag == "" {
-			p = append(p, Label{i, []string{f.Name}})
-		}
-		y := make([]string, 0, 0)
-		for _, x := range strings.Split(tag, ",") {
-			y = append(y, strings.TrimSpace(x))
-		}
-		if y[0] == "" {
-			y[0] = f.Name
-		}
-		p = append(p, Label{i, y})
+// 各类型的标签信息与编解码方案，在首次使用时构建
+type cache struct {
+	label sync.Map       // reflect.Type => []Label
+	conv  sync.Map       // reflect.Type => Converter
+	plan  unsafe.Pointer // *plans，注册转换器时整体替换
+}
+
+// 一组编解码方案，构建中的方案只会存入其开始时的那一组
+type plans struct {
+	enc sync.Map // reflect.Type => encoderFunc
+	dec sync.Map // reflect.Type => decoderFunc
+}
+
+// 获取当前的编解码方案
+func (this *cache) plans() *plans {
+	if p := atomic.LoadPointer(&this.plan); p != nil {
+		return (*plans)(p)
+	}
+	atomic.CompareAndSwapPointer(&this.plan, nil, unsafe.Pointer(new(plans)))
+	return (*plans)(atomic.LoadPointer(&this.plan))
+}
+
+// 创建一个Translator，name为结构体标签的键，raw中的类型编解码时原样传递
+func NewTranslator(name string, raw map[reflect.Type]struct{}) *Translator {
+	return &Translator{Name: name, Raw: raw, cache: unsafe.Pointer(new(cache))}
+}
+
+// 获取缓存，零值的Translator在首次使用时创建
+func (this *Translator) store() *cache {
+	if p := atomic.LoadPointer(&this.cache); p != nil {
+		return (*cache)(p)
 	}
-	this.Tag[x] = p
-	return p
+	atomic.CompareAndSwapPointer(&this.cache, nil, unsafe.Pointer(new(cache)))
+	return (*cache)(atomic.LoadPointer(&this.cache))
 }
 
 // 编码一个值为中间数据
 func (this *Translator) Encode(x reflect.Value) (interface{}, error) {
-	y := x.Type()
-	if _, ok := this.Raw[y]; ok {
-		return x.Interface(), nil
-	}
-	switch x.Kind() {
-	case reflect.Bool:
-		return x.Bool(), nil
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return x.Int(), nil
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return x.Uint(), nil
-	case reflect.Float32, reflect.Float64:
-		return x.Float(), nil
-	case reflect.Complex64, reflect.Complex128:
-		return x.Complex(), nil
-	case reflect.String:
-		return x.String(), nil
-	case reflect.Ptr, reflect.Interface:
-		if x.IsNil() {
-			return nil, nil
-		}
-		return this.Encode(x.Elem())
-	case reflect.Slice:
-		if x.IsNil() {
-			return nil, nil
-		}
-		if y.Elem().Kind() == reflect.Uint8 {
-			return x.Bytes(), nil
-		}
-		fallthrough
-	case reflect.Array:
-		s := []interface{}{}
-		for i, l := 0, x.Len(); i < l; i++ {
-			v, e := this.Encode(x.Index(i))
-			if e != nil {
-				return nil, e
-			}
-			s = append(s, v)
-		}
-		return s, nil
-	case reflect.Map:
-		if x.IsNil() {
-			return nil, nil
-		}
-		s := []Item{}
-		for _, k := range x.MapKeys() {
-			K, e := this.Encode(k)
-			if e != nil {
-				return nil, e
-			}
-			V, e := this.Encode(x.MapIndex(k))
-			if e != nil {
-				return nil, e
-			}
-			s = append(s, Item{K, V})
-		}
-		return s, nil
-	case reflect.Struct:
-		label, ok := this.Tag[y]
-		if !ok {
-			label = this.GetLabel(y)
-		}
-		s := []Attr{}
-		for i := 0; i < len(label); i++ {
-			v := x.Field(label[i].N)
-			if !label[i].Has("omitempty") || !Zero(v) {
-				V, e := this.Encode(v)
-				if e != nil {
-					return nil, e
-				}
-				s = append(s, Attr{label[i].Name(), V})
-			}
-		}
-		return s, nil
+	if !x.IsValid() {
+		return nil, nil
 	}
-	return nil, UnsupportType
+	s := &encodeState{t: this, seen: make(map[visit]int)}
+	return this.encoder(x.Type())(s, x)
 }
 
 // 解码中间数据并填充值
 func (this *Translator) Decode(x reflect.Value, d interface{}) error {
-	y := x.Type()
-	if y == reflect.TypeOf(d) {
-		x.Set(reflect.ValueOf(d))
-		return nil
+	s := &decodeState{t: this, offset: -1}
+	return s.decode(this.decoder(x.Type()), x, d)
+}
+
+// 获取某类型的编码方案，递归类型在构建期间使用间接的方案
+func (this *Translator) encoder(t reflect.Type) encoderFunc {
+	c := this.store().plans()
+	if f, ok := c.enc.Load(t); ok {
+		return f.(encoderFunc)
 	}
-	if _, ok := d.(Undefined); ok {
-		d = nil
+	var (
+		w sync.WaitGroup
+		f encoderFunc
+	)
+	w.Add(1)
+	g, ok := c.enc.LoadOrStore(t, encoderFunc(func(s *encodeState, x reflect.Value) (interface{}, error) {
+		w.Wait()
+		return f(s, x)
+	}))
+	if ok {
+		return g.(encoderFunc)
+	}
+	f = this.newEncoder(t)
+	w.Done()
+	c.enc.Store(t, f)
+	return f
+}
+
+// 获取某类型的解码方案，递归类型在构建期间使用间接的方案
+func (this *Translator) decoder(t reflect.Type) decoderFunc {
+	c := this.store().plans()
+	if f, ok := c.dec.Load(t); ok {
+		return f.(decoderFunc)
 	}
-	switch x.Kind() {
-	case reflect.Bool:
-		if u, ok := d.(bool); ok {
-			x.SetBool(u)
-			return nil
-		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if u, ok := d.(int64); ok {
-			x.SetInt(u)
-			return nil
-		}
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if u, ok := d.(uint64); ok {
-			x.SetUint(u)
-			return nil
-		}
-	case reflect.Float32, reflect.Float64:
-		if u, ok := d.(float64); ok {
-			x.SetFloat(u)
-			return nil
-		}
-	case reflect.Complex64, reflect.Complex128:
-		if u, ok := d.(complex128); ok {
-			x.SetComplex(u)
-			return nil
-		}
-	case reflect.String:
-		if u, ok := d.(string); ok {
-			x.SetString(u)
-			return nil
-		}
-		if u, ok := d.([]byte); ok {
-			x.SetString(string(u))
-			return nil
-		}
-	case reflect.Ptr:
-		if d == nil {
-			x.Set(reflect.Zero(y))
-			return nil
-		} else {
-			if x.IsNil() {
-				x.Set(reflect.New(y))
-			}
-			return this.Decode(x.Elem(), d)
-		}
-	case reflect.Interface:
-		if d == nil {
-			x.Set(reflect.Zero(y))
-		} else {
-			x.Set(reflect.ValueOf(d))
-		}
-		return nil
-	case reflect.Slice:
-		if d == nil {
-			x.Set(reflect.Zero(y))
-			return nil
-		}
-		if y.Elem().Kind() == reflect.Uint8 {
-			if u, ok := d.(string); ok {
-				x.SetBytes([]byte(u))
-				return nil
-			}
-			if u, ok := d.([]byte); ok {
-				x.SetBytes(u)
-				return nil
-			}
-		}
-		if u, ok := d.([]interface{}); ok {
-			n := x
-			for i, l := 0, len(u); i < l; i++ {
-				v := reflect.New(y.Elem()).Elem()
-				e := this.Decode(v, u[i])
-				if e != nil {
-					return e
-				}
-				n = reflect.Append(n, v)
-			}
-			x.Set(n)
-			return nil
-		}
-	case reflect.Array:
-		if u, ok := d.([]interface{}); ok {
-			l := x.Len()
-			if l > len(u) {
-				l = len(u)
-			}
-			for i := 0; i < l; i++ {
-				e := this.Decode(x.Index(i), u[i])
-				if e != nil {
-					return e
-				}
-			}
-			return nil
-		}
-	case reflect.Map:
-		if d == nil {
-			x.Set(reflect.Zero(y))
-			return nil
-		}
-		if u, ok := d.([]Item); ok {
-			for i, l := 0, len(u); i < l; i++ {
-				k := reflect.New(y.Key()).Elem()
-				e := this.Decode(k, u[i].K)
-				if e != nil {
-					return e
-				}
-				v := reflect.New(y.Elem()).Elem()
-				e = this.Decode(v, u[i].V)
-				if e != nil {
-					return e
-				}
-				x.SetMapIndex(k, v)
-			}
-			return nil
-		}
-		if u, ok := d.(map[string]interface{}); ok {
-			if y.Key().Kind() == reflect.String {
-				for K, V := range u {
-					v := reflect.New(y.Elem()).Elem()
-					e := this.Decode(v, V)
-					if e != nil {
-						return e
-					}
-					x.SetMapIndex(reflect.ValueOf(K), v)
-				}
-				return nil
-			}
-		}
-	case reflect.Struct:
-		if u, ok := d.(map[string]interface{}); ok {
-			label, ok := this.Tag[y]
-			if !ok {
-				label = this.GetLabel(y)
-			}
-			for i := 0; i < len(label); i++ {
-				v := x.Field(label[i].N)
-				if w, ok := u[label[i].Name()]; ok {
-					e := this.Decode(v, w)
-					if e != nil {
-						return e
-					}
-				} else if label[i].Has("omitempty") {
-					v.Set(reflect.Zero(v.Type()))
-				}
-			}
-			return nil
-		}
+	var (
+		w sync.WaitGroup
+		f decoderFunc
+	)
+	w.Add(1)
+	g, ok := c.dec.LoadOrStore(t, decoderFunc(func(s *decodeState, x reflect.Value, d interface{}) error {
+		w.Wait()
+		return f(s, x, d)
+	}))
+	if ok {
+		return g.(decoderFunc)
 	}
-	return UnmatchedType
+	f = this.newDecoder(t)
+	w.Done()
+	c.dec.Store(t, f)
+	return f
 }
@@ -0,0 +1,228 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type pair struct {
+	A int    `test:"a"`
+	B string `test:"b,omitempty"`
+}
+
+func TestZeroTranslator(t *testing.T) {
+	var x Translator
+	x.Name = "test"
+	d, e := x.Encode(reflect.ValueOf(pair{1, ""}))
+	if e != nil || !reflect.DeepEqual(d, []Attr{{"a", int64(1)}}) {
+		t.Fatalf("Encode = %#v, %v", d, e)
+	}
+	var y pair
+	if e = x.Decode(reflect.ValueOf(&y).Elem(), map[string]interface{}{"a": int64(2), "b": "c"}); e != nil || y != (pair{2, "c"}) {
+		t.Fatalf("Decode = %+v, %v", y, e)
+	}
+}
+
+func TestPresetTag(t *testing.T) {
+	x := NewTranslator("test", nil)
+	x.Tag = map[reflect.Type][]Label{
+		reflect.TypeOf(pair{}): {{N: 1, V: []string{"renamed"}, I: []int{1}}},
+	}
+	d, e := x.Encode(reflect.ValueOf(pair{1, "s"}))
+	if e != nil || !reflect.DeepEqual(d, []Attr{{"renamed", "s"}}) {
+		t.Fatalf("Encode = %#v, %v", d, e)
+	}
+	// 旧格式的标签只有N，没有I
+	y := NewTranslator("test", nil)
+	y.Tag = map[reflect.Type][]Label{
+		reflect.TypeOf(pair{}): {{N: 1, V: []string{"old"}}},
+	}
+	d, e = y.Encode(reflect.ValueOf(pair{1, "s"}))
+	if e != nil || !reflect.DeepEqual(d, []Attr{{"old", "s"}}) {
+		t.Fatalf("Encode = %#v, %v", d, e)
+	}
+	var z pair
+	if e = y.Decode(reflect.ValueOf(&z).Elem(), map[string]interface{}{"old": "t"}); e != nil || z.B != "t" {
+		t.Fatalf("Decode = %+v, %v", z, e)
+	}
+	if y.Tag[reflect.TypeOf(pair{})][0].I != nil {
+		t.Fatal("preset Tag modified")
+	}
+}
+
+func TestStrictClass(t *testing.T) {
+	for _, c := range []struct {
+		class string
+		key   string
+		ok    bool
+	}{
+		{"", "", false},
+		{"", "$", false},
+		{"$", "$", true},
+		{"$", "", false},
+		{"$", "c", false},
+	} {
+		x := Translator{Name: "test", Class: c.class, Strict: true}
+		var y pair
+		e := x.Decode(reflect.ValueOf(&y).Elem(), map[string]interface{}{"a": int64(1), c.key: "x"})
+		if _, bad := e.(*UnknownFieldError); bad == c.ok || !bad && e != nil {
+			t.Errorf("Class %q, key %q: %v", c.class, c.key, e)
+		}
+	}
+}
+
+type celsius float64
+
+type reading struct {
+	C []celsius `test:"c"`
+}
+
+func TestRegisterConverterConcurrent(t *testing.T) {
+	conv := func(x reflect.Value) (interface{}, error) { return "converted", nil }
+	for round := 0; round < 50; round++ {
+		x := NewTranslator("test", nil)
+		v := reflect.ValueOf(reading{[]celsius{1}})
+		stop := make(chan struct{})
+		var w sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			w.Add(1)
+			go func() {
+				defer w.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						x.Encode(v)
+					}
+				}
+			}()
+		}
+		x.RegisterConverter(reflect.TypeOf(celsius(0)), conv, nil)
+		d, e := x.Encode(v)
+		close(stop)
+		w.Wait()
+		want := []Attr{{"c", []interface{}{"converted"}}}
+		if e != nil || !reflect.DeepEqual(d, want) {
+			t.Fatalf("round %d: Encode = %#v, %v", round, d, e)
+		}
+		if d, _ = x.Encode(v); !reflect.DeepEqual(d, want) {
+			t.Fatalf("round %d: stale plan after registration: %#v", round, d)
+		}
+	}
+}
+
+func TestUnmatchedOffset(t *testing.T) {
+	x := NewTranslator("test", nil)
+	var y pair
+	e := x.Decode(reflect.ValueOf(&y).Elem(), map[string]interface{}{"a": "x"})
+	u, ok := e.(*UnmatchedError)
+	if !ok || u.Offset != -1 || u.Path != "A" || u.Error() != "unmatched type at A: expected int, found string" {
+		t.Fatalf("plain data: %#v", e)
+	}
+	n := &Node{Kind: DictNode, Offset: 3, Dict: []Field{{"a", &Node{Kind: StringNode, Str: "x", Offset: 7}}}}
+	e = x.Decode(reflect.ValueOf(&y).Elem(), n)
+	if u, ok = e.(*UnmatchedError); !ok || u.Offset != 7 {
+		t.Fatalf("node: %v", e)
+	}
+	e = x.Decode(reflect.ValueOf(&y).Elem(), &Node{Kind: ListNode, Offset: 3})
+	if u, ok = e.(*UnmatchedError); !ok || u.Offset != 3 || u.Path != "" {
+		t.Fatalf("node: %v", e)
+	}
+	// 默认值不来自输入，没有偏移
+	var z struct {
+		N int `test:"n,default=x"`
+	}
+	e = x.Decode(reflect.ValueOf(&z).Elem(), &Node{Kind: DictNode, Offset: 3})
+	if u, ok = e.(*UnmatchedError); !ok || u.Offset != -1 || u.Path != "N" {
+		t.Fatalf("default: %v", e)
+	}
+}
+
+// 以分为单位的金额，编码为"元.分"形式的字符串
+type money int64
+
+func (this money) MarshalData() (interface{}, error) {
+	return fmt.Sprintf("%d.%02d", this/100, this%100), nil
+}
+
+func (this *money) UnmarshalData(d interface{}) error {
+	s, ok := d.(string)
+	if !ok {
+		return errors.New("money must be a string")
+	}
+	var a, b int64
+	if _, e := fmt.Sscanf(s, "%d.%d", &a, &b); e != nil {
+		return e
+	}
+	*this = money(a*100 + b)
+	return nil
+}
+
+type account struct {
+	A money  `test:"a"`
+	B *money `test:"b,omitempty"`
+}
+
+func TestMarshaler(t *testing.T) {
+	x := NewTranslator("test", nil)
+	b := money(250)
+	d, e := x.Encode(reflect.ValueOf(account{105, &b}))
+	if e != nil || !reflect.DeepEqual(d, []Attr{{"a", "1.05"}, {"b", "2.50"}}) {
+		t.Fatalf("Encode = %#v, %v", d, e)
+	}
+	var y account
+	e = x.Decode(reflect.ValueOf(&y).Elem(), map[string]interface{}{"a": "3.07", "b": "0.01"})
+	if e != nil || y.A != 307 || y.B == nil || *y.B != 1 {
+		t.Fatalf("Decode = %+v, %v", y, e)
+	}
+	e = x.Decode(reflect.ValueOf(&y).Elem(), map[string]interface{}{"a": int64(1)})
+	if e == nil || e.Error() != "money must be a string" {
+		t.Fatalf("Decode error = %v", e)
+	}
+}
+
+// 以"a-b"形式编码为文本的键
+type point struct{ A, B int }
+
+func (this point) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d-%d", this.A, this.B)), nil
+}
+
+func (this *point) UnmarshalText(b []byte) error {
+	_, e := fmt.Sscanf(string(b), "%d-%d", &this.A, &this.B)
+	return e
+}
+
+func TestTextMarshaler(t *testing.T) {
+	x := NewTranslator("test", nil)
+	for _, c := range []struct {
+		in  interface{}
+		out interface{}
+	}{
+		{point{1, 2}, "1-2"},
+		{&point{3, 4}, "3-4"},
+		{(*point)(nil), nil},
+		{map[point]int{{2, 1}: 5, {1, 9}: 6}, []Item{{"1-9", int64(6)}, {"2-1", int64(5)}}},
+	} {
+		d, e := x.Encode(reflect.ValueOf(c.in))
+		if e != nil || !reflect.DeepEqual(d, c.out) {
+			t.Errorf("Encode(%v) = %#v, %v", c.in, d, e)
+		}
+	}
+	var p point
+	if e := x.Decode(reflect.ValueOf(&p).Elem(), []byte("5-6")); e != nil || p != (point{5, 6}) {
+		t.Errorf("Decode = %v, %v", p, e)
+	}
+	var m map[point]int
+	e := x.Decode(reflect.ValueOf(&m).Elem(), map[string]interface{}{"1-2": int64(3)})
+	if e != nil || !reflect.DeepEqual(m, map[point]int{{1, 2}: 3}) {
+		t.Errorf("Decode = %v, %v", m, e)
+	}
+	if e = x.Decode(reflect.ValueOf(&p).Elem(), int64(1)); !errors.Is(e, UnmatchedType) {
+		t.Errorf("Decode(int) = %v", e)
+	}
+}
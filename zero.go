@@ -2,35 +2,55 @@ package encoding
 
 import "reflect"
 
-// 表示一个标签
-type Label struct {
-	N int // 字段索引
-	V []string
+// 具有IsZero方法的类型
+type isZeroer interface {
+	IsZero() bool
 }
 
-// 字段名称
-func (this *Label) Name() string {
-	return this.V[0]
+// 判断一个值是否为零值，空的slice和map也视为零值
+func Zero(x reflect.Value) bool {
+	return ZeroWith(x, true)
 }
 
-// 是否具有某属性
-func (this *Label) Has(s string) bool {
-	for i := 1; i < len(this.V); i++ {
-		if this.V[i] == s {
+// 判断一个值是否为零值，empty为假时只有nil的slice和map视为零值
+//
+// 值（或可寻址时其指针）具有IsZero() bool方法时使用该方法，nil指针总视为零值；
+// 非nil的接口只在其持有的值具有IsZero方法且返回真时视为零值，持有nil指针时不是零值
+func ZeroWith(x reflect.Value, empty bool) bool {
+	switch x.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr:
+		if x.IsNil() {
+			return true
+		}
+	case reflect.Interface:
+		if x.IsNil() {
 			return true
 		}
+		if e := x.Elem(); x.CanInterface() && (e.Kind() != reflect.Ptr || !e.IsNil()) {
+			if z, ok := e.Interface().(isZeroer); ok {
+				return z.IsZero()
+			}
+		}
+		return false
+	}
+	if x.CanInterface() {
+		if z, ok := x.Interface().(isZeroer); ok {
+			return z.IsZero()
+		}
+		if x.CanAddr() {
+			if z, ok := x.Addr().Interface().(isZeroer); ok {
+				return z.IsZero()
+			}
+		}
 	}
-	return false
-}
-
-// 判断一个值是否为零值
-func Zero(x reflect.Value) bool {
 	switch x.Kind() {
 	case reflect.Bool:
 		return x.Bool() == false
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return x.Int() == 0
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 		return x.Uint() == 0
 	case reflect.Float32, reflect.Float64:
 		return x.Float() == 0
@@ -43,16 +63,16 @@ func Zero(x reflect.Value) bool {
 	case reflect.Interface, reflect.Ptr, reflect.Chan, reflect.Func:
 		return x.IsNil()
 	case reflect.Slice, reflect.Map:
-		return x.IsNil() || x.Len() == 0
+		return x.IsNil() || empty && x.Len() == 0
 	case reflect.Array:
 		for i, l := 0, x.Len(); i < l; i++ {
-			if !Zero(x.Index(i)) {
+			if !ZeroWith(x.Index(i), empty) {
 				return false
 			}
 		}
 	case reflect.Struct:
 		for i, l := 0, x.NumField(); i < l; i++ {
-			if !Zero(x.Field(i)) {
+			if !ZeroWith(x.Field(i), empty) {
 				return false
 			}
 		}
@@ -0,0 +1,83 @@
+package encoding
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 值为7时视为零值的指针方法
+type lucky int
+
+func (this *lucky) IsZero() bool {
+	return *this == 7
+}
+
+func TestZero(t *testing.T) {
+	var (
+		nilTime *time.Time
+		l       = lucky(7)
+	)
+	for _, c := range []struct {
+		x     interface{}
+		zero  bool // Zero的结果
+		keep  bool // ZeroWith(x, false)的结果
+		field bool // 作为interface{}字段时Zero的结果
+	}{
+		{0, true, true, false},
+		{uintptr(0), true, true, false},
+		{uintptr(1), false, false, false},
+		{"", true, true, false},
+		{[]int{}, true, false, false},
+		{[]int(nil), true, true, false},
+		{map[string]int{}, true, false, false},
+		{[2]int{}, true, true, false},
+		{[2][]int{{}, nil}, true, false, false},
+		{struct{ A []int }{[]int{}}, true, false, false},
+		{time.Time{}, true, true, true},
+		{time.Unix(1, 0), false, false, false},
+		{nilTime, true, true, false},
+		{&l, true, true, true},
+		{lucky(7), false, false, false},
+	} {
+		v := reflect.ValueOf(c.x)
+		if got := Zero(v); got != c.zero {
+			t.Errorf("Zero(%#v) = %v", c.x, got)
+		}
+		if got := ZeroWith(v, false); got != c.keep {
+			t.Errorf("ZeroWith(%#v, false) = %v", c.x, got)
+		}
+		f := reflect.ValueOf(&struct{ V interface{} }{c.x}).Elem().Field(0)
+		if got := Zero(f); got != c.field {
+			t.Errorf("Zero(interface{}(%#v)) = %v", c.x, got)
+		}
+	}
+	// 可寻址时使用指针的IsZero方法
+	if !Zero(reflect.ValueOf(&l).Elem()) {
+		t.Error("addressable lucky(7) is not zero")
+	}
+	if !Zero(reflect.ValueOf(struct{ V interface{} }{}).Field(0)) {
+		t.Error("nil interface is not zero")
+	}
+}
+
+func TestOmitNilInInterface(t *testing.T) {
+	x := NewTranslator("test", nil)
+	type holder struct {
+		V interface{} `test:"v,omitempty"`
+		Z interface{} `test:"z,omitzero"`
+	}
+	for _, c := range []struct {
+		in   holder
+		want []Attr
+	}{
+		{holder{(*time.Time)(nil), (*time.Time)(nil)}, []Attr{{"v", nil}, {"z", nil}}},
+		{holder{time.Time{}, time.Time{}}, []Attr{}},
+		{holder{}, []Attr{}},
+	} {
+		d, e := x.Encode(reflect.ValueOf(c.in))
+		if e != nil || !reflect.DeepEqual(d, c.want) {
+			t.Errorf("Encode(%+v) = %#v, %v", c.in, d, e)
+		}
+	}
+}